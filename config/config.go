@@ -1,18 +1,67 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+)
+
+// DefaultBucketName is the name of the bucket described by the top level
+// configuration when no BUCKETS_CONFIG is given.
+const DefaultBucketName = "default"
+
+const (
+	// StorageBackendS3 stores objects in an Amazon S3 bucket.
+	StorageBackendS3 = "s3"
+	// StorageBackendLocal stores objects in a directory tree on disk.
+	StorageBackendLocal = "local"
+	// StorageBackendMemory keeps objects in process memory.
+	StorageBackendMemory = "memory"
 )
 
 type Config struct {
+	Name                 string `json:"-"`
+	StorageBackend       string `json:"storageBackend"`
 	BucketName           string `json:"bucketName"`
 	Region               string `json:"region"`
 	DownloadURLTimeLimit int    `json:"downloadURLTimeLimit"`
 	PaginationPageSize   int    `json:"paginationPageSize"`
 	AwsAccessKeyID       string `json:"awsAccessKeyId"`
 	AwsSecretAccessKey   string `json:"awsSecretAccessKey"`
+	AwsSessionToken      string `json:"awsSessionToken"`
+	AwsProfile           string `json:"awsProfile"`
+	S3Endpoint           string `json:"s3Endpoint"`
+	S3ForcePathStyle     bool   `json:"s3ForcePathStyle"`
+	S3DisableSSL         bool   `json:"s3DisableSSL"`
+	S3CABundle           string `json:"s3CABundle"`
+	UploadPartSizeMB     int    `json:"uploadPartSizeMB"`
+	UploadConcurrency    int    `json:"uploadConcurrency"`
+	TusStateDir          string `json:"-"`
+	TusMaxSize           int64  `json:"-"`
+	TusExpiryHours       int    `json:"-"`
+	IndexEnabled         bool   `json:"-"`
+	IndexDir             string `json:"-"`
+	IndexSnapshotMinutes int    `json:"-"`
+	IndexTags            bool   `json:"-"`
+	FullTextEnabled      bool   `json:"-"`
+	FullTextMaxSizeMB    int    `json:"-"`
+	UsageCacheTTL        int    `json:"-"`
+	JobStateDir          string `json:"-"`
+	JobConcurrency       int    `json:"-"`
+	TrashEnabled         bool   `json:"-"`
+	TrashPrefix          string `json:"-"`
+	TrashRetentionDays   int    `json:"-"`
+	LocalStorageRoot     string `json:"localStorageRoot"`
+	PublicURL            string `json:"publicUrl"`
+	URLSigningKey        string `json:"urlSigningKey"`
+
+	// Buckets is the allowlist of buckets clients can select with the
+	// "bucket" parameter, keyed by the name clients use.
+	Buckets       map[string]*Config `json:"-"`
+	DefaultBucket string             `json:"-"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -20,20 +69,39 @@ func LoadConfig() (*Config, error) {
 	config := &Config{}
 
 	// Retrieve and assign the values from environment variables
+	config.StorageBackend = os.Getenv("STORAGE_BACKEND")
 	config.BucketName = os.Getenv("BUCKET_NAME")
 	config.Region = os.Getenv("REGION")
 	config.DownloadURLTimeLimit, _ = strconv.Atoi(os.Getenv("DOWNLOAD_URL_TIME_LIMIT"))
 	config.PaginationPageSize, _ = strconv.Atoi(os.Getenv("PAGINATION_PAGE_SIZE"))
 	config.AwsAccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
 	config.AwsSecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
-
-	if config.BucketName == "" {
-		return nil, fmt.Errorf("BUCKET_NAME must be set")
-	}
-
-	if config.Region == "" {
-		return nil, fmt.Errorf("REGION must be set")
-	}
+	config.AwsSessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	config.AwsProfile = os.Getenv("AWS_PROFILE")
+	config.S3Endpoint = os.Getenv("S3_ENDPOINT")
+	config.S3ForcePathStyle, _ = strconv.ParseBool(os.Getenv("S3_FORCE_PATH_STYLE"))
+	config.S3DisableSSL, _ = strconv.ParseBool(os.Getenv("S3_DISABLE_SSL"))
+	config.S3CABundle = os.Getenv("S3_CA_BUNDLE")
+	config.UploadPartSizeMB, _ = strconv.Atoi(os.Getenv("UPLOAD_PART_SIZE_MB"))
+	config.UploadConcurrency, _ = strconv.Atoi(os.Getenv("UPLOAD_CONCURRENCY"))
+	config.TusStateDir = os.Getenv("TUS_STATE_DIR")
+	config.TusMaxSize, _ = strconv.ParseInt(os.Getenv("TUS_MAX_SIZE"), 10, 64)
+	config.TusExpiryHours, _ = strconv.Atoi(os.Getenv("TUS_EXPIRY_HOURS"))
+	config.IndexEnabled, _ = strconv.ParseBool(os.Getenv("INDEX_ENABLED"))
+	config.IndexDir = os.Getenv("INDEX_DIR")
+	config.IndexSnapshotMinutes, _ = strconv.Atoi(os.Getenv("INDEX_SNAPSHOT_INTERVAL"))
+	config.IndexTags, _ = strconv.ParseBool(os.Getenv("INDEX_TAGS"))
+	config.FullTextEnabled, _ = strconv.ParseBool(os.Getenv("FULLTEXT_ENABLED"))
+	config.FullTextMaxSizeMB, _ = strconv.Atoi(os.Getenv("FULLTEXT_MAX_SIZE_MB"))
+	config.UsageCacheTTL, _ = strconv.Atoi(os.Getenv("USAGE_CACHE_TTL"))
+	config.JobStateDir = os.Getenv("JOB_STATE_DIR")
+	config.JobConcurrency, _ = strconv.Atoi(os.Getenv("JOB_CONCURRENCY"))
+	config.TrashEnabled, _ = strconv.ParseBool(os.Getenv("TRASH_ENABLED"))
+	config.TrashPrefix = os.Getenv("TRASH_PREFIX")
+	config.TrashRetentionDays, _ = strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	config.LocalStorageRoot = os.Getenv("LOCAL_STORAGE_ROOT")
+	config.PublicURL = os.Getenv("PUBLIC_URL")
+	config.URLSigningKey = os.Getenv("URL_SIGNING_KEY")
 
 	if config.DownloadURLTimeLimit == 0 {
 		config.DownloadURLTimeLimit = 15
@@ -43,13 +111,184 @@ func LoadConfig() (*Config, error) {
 		config.PaginationPageSize = 100
 	}
 
-	if config.AwsAccessKeyID == "" {
-		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID must be set")
+	if config.UploadPartSizeMB == 0 {
+		config.UploadPartSizeMB = 16
+	}
+
+	if config.UploadConcurrency <= 0 {
+		config.UploadConcurrency = 4
+	}
+
+	if config.TusStateDir == "" {
+		config.TusStateDir = "./tus-uploads"
+	}
+
+	if config.TusExpiryHours <= 0 {
+		config.TusExpiryHours = 24
+	}
+
+	if config.IndexDir == "" {
+		config.IndexDir = "./index"
+	}
+
+	if config.IndexSnapshotMinutes <= 0 {
+		config.IndexSnapshotMinutes = 5
 	}
 
-	if config.AwsSecretAccessKey == "" {
-		return nil, fmt.Errorf("AWS_SECRET_ACCESS_KEY must be set")
+	// the text index is built from the objects found by the metadata index
+	if config.FullTextEnabled {
+		config.IndexEnabled = true
+	}
+
+	if config.FullTextMaxSizeMB <= 0 {
+		config.FullTextMaxSizeMB = 10
+	}
+
+	if config.UsageCacheTTL <= 0 {
+		config.UsageCacheTTL = 300
+	}
+
+	if config.JobStateDir == "" {
+		config.JobStateDir = "./jobs"
+	}
+
+	if config.JobConcurrency <= 0 {
+		config.JobConcurrency = 8
+	}
+
+	if config.TrashPrefix == "" {
+		config.TrashPrefix = ".trash/"
+	}
+
+	// the trash is a folder of its own
+	if !strings.HasSuffix(config.TrashPrefix, "/") {
+		config.TrashPrefix += "/"
+	}
+
+	if config.TrashRetentionDays <= 0 {
+		config.TrashRetentionDays = 30
+	}
+
+	bucketsFile := os.Getenv("BUCKETS_CONFIG")
+	config.DefaultBucket = os.Getenv("DEFAULT_BUCKET")
+
+	// Without a buckets file the top level configuration is the only bucket
+	if bucketsFile == "" {
+		config.Name = DefaultBucketName
+		if err := config.validate(); err != nil {
+			return nil, err
+		}
+
+		config.Buckets = map[string]*Config{DefaultBucketName: config}
+		config.DefaultBucket = DefaultBucketName
+
+		return config, nil
+	}
+
+	buckets, err := loadBuckets(bucketsFile, config)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Buckets = buckets
+
+	if _, found := buckets[config.DefaultBucket]; config.DefaultBucket != "" && !found {
+		return nil, fmt.Errorf("DEFAULT_BUCKET %q is not defined in %s", config.DefaultBucket, bucketsFile)
 	}
 
 	return config, nil
 }
+
+// loadBuckets reads the bucket allowlist from a JSON file mapping bucket names
+// to configurations. Fields not set for a bucket are inherited from base.
+func loadBuckets(file string, base *Config) (map[string]*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read BUCKETS_CONFIG: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse BUCKETS_CONFIG: %w", err)
+	}
+
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("BUCKETS_CONFIG must define at least one bucket")
+	}
+
+	buckets := make(map[string]*Config, len(raw))
+	for name, message := range raw {
+		bucket := *base
+		if err := json.Unmarshal(message, &bucket); err != nil {
+			return nil, fmt.Errorf("failed to parse bucket %q: %w", name, err)
+		}
+
+		bucket.Name = name
+		if err := bucket.validate(); err != nil {
+			return nil, fmt.Errorf("bucket %q: %w", name, err)
+		}
+
+		buckets[name] = &bucket
+	}
+
+	return buckets, nil
+}
+
+// BucketNames returns the names of all configured buckets in sorted order.
+func (config *Config) BucketNames() []string {
+	names := make([]string, 0, len(config.Buckets))
+	for name := range config.Buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// validate checks the storage settings of a single bucket and fills in defaults.
+func (config *Config) validate() error {
+	if config.StorageBackend == "" {
+		config.StorageBackend = StorageBackendS3
+	}
+
+	switch config.StorageBackend {
+	case StorageBackendS3:
+		if config.BucketName == "" {
+			return fmt.Errorf("BUCKET_NAME must be set")
+		}
+
+		// S3 compatible servers such as MinIO don't care about the region,
+		// but the SDK still needs one to sign requests
+		if config.Region == "" && config.S3Endpoint != "" {
+			config.Region = "us-east-1"
+		}
+
+		if config.Region == "" {
+			return fmt.Errorf("REGION must be set")
+		}
+
+		// S3 rejects parts below 5 MB, except for the last one
+		if config.UploadPartSizeMB < 5 {
+			return fmt.Errorf("UPLOAD_PART_SIZE_MB must be at least 5")
+		}
+
+		// Static keys are optional, without them the AWS default credential
+		// chain is used. Only one half of a key pair is a configuration error.
+		if (config.AwsAccessKeyID == "") != (config.AwsSecretAccessKey == "") {
+			return fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set together")
+		}
+
+	case StorageBackendLocal:
+		if config.LocalStorageRoot == "" {
+			config.LocalStorageRoot = "./data"
+		}
+
+	case StorageBackendMemory:
+		// nothing to configure
+
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND %q", config.StorageBackend)
+	}
+
+	return nil
+}
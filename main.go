@@ -1,15 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"file-management-service/config"
 	"file-management-service/pkg/cache"
+	"file-management-service/pkg/index"
+	"file-management-service/pkg/jobs"
+	"file-management-service/pkg/local"
+	"file-management-service/pkg/memory"
+	"file-management-service/pkg/s3"
+	"file-management-service/pkg/storage"
+	"file-management-service/pkg/trash"
+	"file-management-service/pkg/tus"
 	"file-management-service/routes"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	// embed the time zone database, so filters can use any time zone even
+	// where the host has none installed
+	_ "time/tzdata"
+
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -29,6 +45,18 @@ func getPort() string {
 	return port
 }
 
+// newBackend creates the storage backend selected by STORAGE_BACKEND
+func newBackend(cfg *config.Config) (storage.Storage, error) {
+	switch cfg.StorageBackend {
+	case config.StorageBackendLocal:
+		return local.NewClient(cfg)
+	case config.StorageBackendMemory:
+		return memory.NewClient(cfg)
+	default:
+		return s3.NewClient(cfg)
+	}
+}
+
 func main() {
 	e := echo.New()
 
@@ -61,8 +89,22 @@ func main() {
 	// Apply rate limiter middleware
 	e.Use(middleware.RateLimiterWithConfig(rateLimiterConfig))
 
-	// Apply CORS middleware
-	e.Use(middleware.CORS())
+	// Apply CORS middleware. Plain OPTIONS requests (not CORS preflights) are
+	// passed on, tus clients use them to discover the server's capabilities.
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		Skipper: func(c echo.Context) bool {
+			req := c.Request()
+			return req.Method == http.MethodOptions && req.Header.Get(echo.HeaderAccessControlRequestMethod) == ""
+		},
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
+		ExposeHeaders: []string{
+			echo.HeaderLocation, "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size",
+			"Tus-Checksum-Algorithm", "Upload-Offset", "Upload-Length", "Upload-Expires",
+			"ETag", echo.HeaderLastModified, "Accept-Ranges", "Content-Range",
+			"X-Storage-Class", "X-Server-Side-Encryption", "X-Version-Id",
+		},
+	}))
 
 	config, err := config.LoadConfig()
 	if err != nil {
@@ -72,20 +114,108 @@ func main() {
 	// Assign the configuration to the global variable
 	AppConfig = config
 
-	cache := cache.NewURLCache()
+	// Create the storage backend of every allowed bucket once and share them
+	// between all requests
+	buckets := storage.NewRegistry(AppConfig.DefaultBucket)
+	for _, name := range AppConfig.BucketNames() {
+		bucketConfig := AppConfig.Buckets[name]
+
+		backend, err := newBackend(bucketConfig)
+		if err != nil {
+			log.Fatalf("Failed to create %s storage backend for bucket %s: %s", bucketConfig.StorageBackend, name, err)
+		}
+
+		buckets.Register(storage.NewClient(name, backend))
+	}
+
+	// Resumable upload sessions are kept on disk and survive restarts
+	uploads, err := tus.NewHandler(AppConfig, buckets, "/tus/")
+	if err != nil {
+		log.Fatalf("Failed to create resumable upload handler: %s", err)
+	}
+
+	// Listing cursors are signed, so they cannot be altered or used for
+	// another folder
+	cursors, err := storage.NewCursorSigner(AppConfig.URLSigningKey)
+	if err != nil {
+		log.Fatalf("Failed to create listing cursor signer: %s", err)
+	}
+
+	urlCache := cache.NewURLCache()
+
+	// Folder usage summaries take a full listing, so they are kept for a while
+	usageCache := cache.NewTTLCache[*storage.FolderUsage](time.Duration(AppConfig.UsageCacheTTL) * time.Second)
 
-	// spawn a goroutine to clear the cache every 5 minutes
+	// spawn a goroutine to clear the caches every 5 minutes
 	go func() {
 		for {
 			time.Sleep(5 * time.Minute)
-			cache.Clear()
+			urlCache.Clear()
+			usageCache.Clear()
 		}
 	}()
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Abandoned resumable uploads expire and are cleaned up in the background
+	uploads.Start(ctx)
+
+	// Deletes go to the trash, which is hidden from the listings, so it has
+	// to be set up before the index crawls the buckets
+	var trashBin *trash.Trash
+	if AppConfig.TrashEnabled {
+		trashBin = trash.New(AppConfig, buckets, urlCache)
+		trashBin.Start(ctx)
+	}
+
+	// The metadata index observes the writes made through the clients, so it
+	// has to be created before the first request
+	var indexer *index.Indexer
+	if AppConfig.IndexEnabled {
+		indexer, err = index.NewIndexer(AppConfig, buckets)
+		if err != nil {
+			log.Fatalf("Failed to create metadata index: %s", err)
+		}
+		indexer.Start(ctx)
+	}
+
+	// Background jobs left unfinished by the last run are resumed
+	jobManager, err := jobs.NewManager(AppConfig, buckets, urlCache)
+	if err != nil {
+		log.Fatalf("Failed to create job manager: %s", err)
+	}
+	jobManager.Start(ctx)
+
 	// Register routes
-	routes.RegisterRoutes(e, AppConfig, cache)
+	routes.RegisterRoutes(e, AppConfig, buckets, uploads, indexer, urlCache, usageCache, cursors, jobManager, trashBin)
 
 	// Start the server
-	e.Start(getPort())
+	go func() {
+		if err := e.Start(getPort()); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %s", err)
+		}
+	}()
 	log.Println("Server Started!!!")
+
+	// Shut down gracefully, so the index snapshots are up to date
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server: %s", err)
+	}
+
+	jobManager.Close()
+	uploads.Close()
+
+	if trashBin != nil {
+		trashBin.Close()
+	}
+
+	if indexer != nil {
+		indexer.Close()
+	}
 }
@@ -32,6 +32,14 @@ func (c *URLCache) Set(key string, url string, expiry time.Time) {
 	c.mutex.Unlock()
 }
 
+// Delete removes the cached URL for key, for objects that were moved or
+// deleted.
+func (c *URLCache) Delete(key string) {
+	c.mutex.Lock()
+	delete(c.cache, key)
+	c.mutex.Unlock()
+}
+
 // run a cron job to clear the cache every 5 minutes
 func (c *URLCache) Clear() {
 	c.mutex.Lock()
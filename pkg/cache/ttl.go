@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLCache caches the results of expensive computations for a fixed time.
+// Concurrent lookups of the same missing key share a single computation.
+type TTLCache[V any] struct {
+	ttl      time.Duration
+	mutex    sync.Mutex
+	entries  map[string]ttlEntry[V]
+	inflight map[string]*call[V]
+}
+
+type ttlEntry[V any] struct {
+	value      V
+	expiryTime time.Time
+}
+
+// call is a computation in progress.
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// NewTTLCache creates a cache keeping values for ttl.
+func NewTTLCache[V any](ttl time.Duration) *TTLCache[V] {
+	return &TTLCache[V]{
+		ttl:      ttl,
+		entries:  make(map[string]ttlEntry[V]),
+		inflight: make(map[string]*call[V]),
+	}
+}
+
+// Get returns the cached value for key, calling compute when there is none
+// or refresh is set. Errors are not cached.
+func (c *TTLCache[V]) Get(key string, refresh bool, compute func() (V, error)) (V, error) {
+	c.mutex.Lock()
+
+	if entry, found := c.entries[key]; found && !refresh && time.Now().Before(entry.expiryTime) {
+		c.mutex.Unlock()
+		return entry.value, nil
+	}
+
+	if pending, found := c.inflight[key]; found {
+		c.mutex.Unlock()
+		<-pending.done
+		return pending.value, pending.err
+	}
+
+	pending := &call[V]{done: make(chan struct{})}
+	c.inflight[key] = pending
+	c.mutex.Unlock()
+
+	pending.value, pending.err = compute()
+
+	c.mutex.Lock()
+	delete(c.inflight, key)
+	if pending.err == nil {
+		c.entries[key] = ttlEntry[V]{
+			value:      pending.value,
+			expiryTime: time.Now().Add(c.ttl),
+		}
+	}
+	c.mutex.Unlock()
+
+	close(pending.done)
+
+	return pending.value, pending.err
+}
+
+// Clear removes the expired entries.
+func (c *TTLCache[V]) Clear() {
+	c.mutex.Lock()
+	for key, entry := range c.entries {
+		if time.Now().After(entry.expiryTime) {
+			delete(c.entries, key)
+		}
+	}
+	c.mutex.Unlock()
+}
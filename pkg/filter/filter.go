@@ -0,0 +1,443 @@
+// Package filter implements a small expression language for filtering file
+// listings, for example
+//
+//	ext in (png, jpg) and size > 10MB and modified >= 2025-01-01 or name ~ "invoice*"
+//
+// Comparisons are combined with or, and and not (in that order of increasing
+// precedence) and can be grouped with parentheses. The fields are
+//
+//	name      base name of the file or folder
+//	path      full key
+//	ext       lower case extension without the dot
+//	type      file or folder
+//	size      size in bytes, values may use the units B, KB, MB, GB and TB
+//	modified  last modified time, values are ISO 8601 dates or timestamps
+//
+// name, path, ext and type support =, !=, ~ and !~ (glob match) and in; size and
+// modified support =, !=, <, <=, >, >= and in. Text comparisons ignore case. A
+// date stands for the whole day, so modified <= 2025-01-31 includes that day.
+package filter
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"file-management-service/pkg/storage"
+)
+
+// SyntaxError is returned by Parse for filters that cannot be parsed. Pos is
+// the byte offset in the filter the error was found at.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid filter at position %d: %s", e.Pos, e.Msg)
+}
+
+// Expression is a parsed filter.
+type Expression struct {
+	source string
+	root   node
+}
+
+// Parse parses a filter. Dates and timestamps without a UTC offset are read
+// in location, or UTC when it is nil.
+func Parse(input string, location *time.Location) (*Expression, error) {
+	if location == nil {
+		location = time.UTC
+	}
+
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, location: location}
+	if p.peek().kind == tokenEOF {
+		return nil, &SyntaxError{Pos: 0, Msg: "empty filter"}
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t, `"and", "or" or the end of the filter`)
+	}
+
+	return &Expression{source: input, root: root}, nil
+}
+
+// Match reports whether an object passes the filter.
+func (e *Expression) Match(object storage.ObjectDetails) bool {
+	return e.root.match(&object)
+}
+
+// String returns the filter as it was parsed.
+func (e *Expression) String() string {
+	return e.source
+}
+
+type node interface {
+	match(object *storage.ObjectDetails) bool
+}
+
+type andNode []node
+
+func (n andNode) match(object *storage.ObjectDetails) bool {
+	for _, operand := range n {
+		if !operand.match(object) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode []node
+
+func (n orNode) match(object *storage.ObjectDetails) bool {
+	for _, operand := range n {
+		if operand.match(object) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) match(object *storage.ObjectDetails) bool {
+	return !n.operand.match(object)
+}
+
+// predicate is a single comparison of a field against a value.
+type predicate func(object *storage.ObjectDetails) bool
+
+func (p predicate) match(object *storage.ObjectDetails) bool {
+	return p(object)
+}
+
+type parser struct {
+	tokens   []token
+	next     int
+	location *time.Location
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) unexpected(t token, expected string) error {
+	return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("expected %s, found %s", expected, t)}
+}
+
+func (p *parser) parseOr() (node, error) {
+	operands := orNode{}
+	for {
+		operand, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+
+		if !p.peek().keyword("or") {
+			break
+		}
+		p.advance()
+	}
+
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return operands, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	operands := andNode{}
+	for {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+
+		if !p.peek().keyword("and") {
+			break
+		}
+		p.advance()
+	}
+
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return operands, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.peek().keyword("not") {
+		p.advance()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+
+	if p.peek().kind == tokenOpen {
+		p.advance()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if t := p.advance(); t.kind != tokenClose {
+			return nil, p.unexpected(t, `")"`)
+		}
+		return inner, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	fieldToken := p.advance()
+	if fieldToken.kind != tokenWord {
+		return nil, p.unexpected(fieldToken, "a field name")
+	}
+
+	f, found := fields[strings.ToLower(fieldToken.text)]
+	if !found {
+		return nil, &SyntaxError{Pos: fieldToken.pos, Msg: fmt.Sprintf("unknown field %q, expected one of name, path, ext, type, size or modified", fieldToken.text)}
+	}
+
+	operatorToken := p.advance()
+	if operatorToken.keyword("in") {
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+
+		var operands orNode
+		for _, value := range values {
+			operand, err := f.compare(p, "=", value)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, operand)
+		}
+		return operands, nil
+	}
+
+	if operatorToken.kind != tokenOperator {
+		return nil, p.unexpected(operatorToken, "an operator")
+	}
+
+	value := p.advance()
+	if value.kind != tokenWord && value.kind != tokenString {
+		return nil, p.unexpected(value, "a value")
+	}
+
+	return f.compare(p, operatorToken.text, value)
+}
+
+// parseList parses the parenthesized, comma separated values after "in".
+func (p *parser) parseList() ([]token, error) {
+	if t := p.advance(); t.kind != tokenOpen {
+		return nil, p.unexpected(t, `"(" after "in"`)
+	}
+
+	var values []token
+	for {
+		value := p.advance()
+		if value.kind != tokenWord && value.kind != tokenString {
+			return nil, p.unexpected(value, "a value")
+		}
+		values = append(values, value)
+
+		switch t := p.advance(); t.kind {
+		case tokenComma:
+			continue
+		case tokenClose:
+			return values, nil
+		default:
+			return nil, p.unexpected(t, `"," or ")"`)
+		}
+	}
+}
+
+// field compiles a comparison of one field of an object.
+type field struct {
+	compare func(p *parser, operator string, value token) (node, error)
+}
+
+var fields = map[string]field{
+	"name": textField(func(object *storage.ObjectDetails) string {
+		return path.Base(object.Name)
+	}),
+	"path": textField(func(object *storage.ObjectDetails) string {
+		return object.Name
+	}),
+	"ext": textField(func(object *storage.ObjectDetails) string {
+		if object.IsFolder {
+			return ""
+		}
+		return strings.TrimPrefix(path.Ext(object.Name), ".")
+	}),
+	"type":     {compare: compareType},
+	"size":     {compare: compareSize},
+	"modified": {compare: compareModified},
+}
+
+// textField compares a text value of an object, ignoring case.
+func textField(get func(object *storage.ObjectDetails) string) field {
+	return field{compare: func(p *parser, operator string, value token) (node, error) {
+		expected := strings.ToLower(value.text)
+
+		switch operator {
+		case "=", "!=":
+			negate := operator == "!="
+			return predicate(func(object *storage.ObjectDetails) bool {
+				return (strings.ToLower(get(object)) == expected) != negate
+			}), nil
+		case "~", "!~":
+			glob, err := storage.CompileGlob(expected)
+			if err != nil {
+				return nil, &SyntaxError{Pos: value.pos, Msg: err.Error()}
+			}
+
+			negate := operator == "!~"
+			return predicate(func(object *storage.ObjectDetails) bool {
+				return glob.Match(strings.ToLower(get(object))) != negate
+			}), nil
+		}
+
+		return nil, &SyntaxError{Pos: value.pos, Msg: fmt.Sprintf("operator %q cannot be used with text, use =, !=, ~, !~ or in", operator)}
+	}}
+}
+
+func compareType(p *parser, operator string, value token) (node, error) {
+	var folder bool
+	switch strings.ToLower(value.text) {
+	case "file":
+	case "folder":
+		folder = true
+	default:
+		return nil, &SyntaxError{Pos: value.pos, Msg: fmt.Sprintf("invalid type %q, expected file or folder", value.text)}
+	}
+
+	switch operator {
+	case "=":
+		return predicate(func(object *storage.ObjectDetails) bool {
+			return object.IsFolder == folder
+		}), nil
+	case "!=":
+		return predicate(func(object *storage.ObjectDetails) bool {
+			return object.IsFolder != folder
+		}), nil
+	}
+
+	return nil, &SyntaxError{Pos: value.pos, Msg: fmt.Sprintf("operator %q cannot be used with type, use =, != or in", operator)}
+}
+
+// units are the size suffixes, longest first so that "MB" is not read as "B"
+var units = []struct {
+	suffix string
+	size   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+func compareSize(p *parser, operator string, value token) (node, error) {
+	number, multiplier := strings.ToUpper(value.text), int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSuffix(number, unit.suffix), unit.size
+			break
+		}
+	}
+
+	parsed, err := strconv.ParseFloat(number, 64)
+	if err != nil || parsed < 0 {
+		return nil, &SyntaxError{Pos: value.pos, Msg: fmt.Sprintf("invalid size %q, expected a number with an optional unit like 10MB", value.text)}
+	}
+	size := int64(parsed * float64(multiplier))
+
+	var compare func(size, expected int64) bool
+	switch operator {
+	case "=":
+		compare = func(size, expected int64) bool { return size == expected }
+	case "!=":
+		compare = func(size, expected int64) bool { return size != expected }
+	case "<":
+		compare = func(size, expected int64) bool { return size < expected }
+	case "<=":
+		compare = func(size, expected int64) bool { return size <= expected }
+	case ">":
+		compare = func(size, expected int64) bool { return size > expected }
+	case ">=":
+		compare = func(size, expected int64) bool { return size >= expected }
+	default:
+		return nil, &SyntaxError{Pos: value.pos, Msg: fmt.Sprintf("operator %q cannot be used with size", operator)}
+	}
+
+	return predicate(func(object *storage.ObjectDetails) bool {
+		return compare(object.Size, size)
+	}), nil
+}
+
+// compareModified compares the last modified time with a date or timestamp.
+// Both are treated as the interval [from, to): the whole day for a date, and
+// a single instant for a timestamp.
+func compareModified(p *parser, operator string, value token) (node, error) {
+	from, err := storage.ParseTimestamp(value.text, p.location, false)
+	if err != nil {
+		return nil, &SyntaxError{Pos: value.pos, Msg: err.Error()}
+	}
+
+	to, _ := storage.ParseTimestamp(value.text, p.location, true)
+	if to.Equal(from) {
+		to = from.Add(time.Nanosecond)
+	}
+
+	var compare func(t time.Time) bool
+	switch operator {
+	case "=":
+		compare = func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+	case "!=":
+		compare = func(t time.Time) bool { return t.Before(from) || !t.Before(to) }
+	case "<":
+		compare = func(t time.Time) bool { return t.Before(from) }
+	case "<=":
+		compare = func(t time.Time) bool { return t.Before(to) }
+	case ">":
+		compare = func(t time.Time) bool { return !t.Before(to) }
+	case ">=":
+		compare = func(t time.Time) bool { return !t.Before(from) }
+	default:
+		return nil, &SyntaxError{Pos: value.pos, Msg: fmt.Sprintf("operator %q cannot be used with modified", operator)}
+	}
+
+	return predicate(func(object *storage.ObjectDetails) bool {
+		return compare(object.LastModified)
+	}), nil
+}
@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"file-management-service/pkg/storage"
+)
+
+var (
+	invoice = storage.ObjectDetails{Name: "docs/Invoice-2025.pdf", Size: 200 << 10, LastModified: time.Date(2025, 1, 31, 23, 0, 0, 0, time.UTC)}
+	photo   = storage.ObjectDetails{Name: "photos/beach.png", Size: 20 << 20, LastModified: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	folder  = storage.ObjectDetails{Name: "photos/2024/", IsFolder: true, LastModified: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)}
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   []storage.ObjectDetails
+	}{
+		{`ext = pdf`, []storage.ObjectDetails{invoice}},
+		{`ext in (png, JPG)`, []storage.ObjectDetails{photo}},
+		{`name ~ "invoice*"`, []storage.ObjectDetails{invoice}},
+		{`name !~ "*.png"`, []storage.ObjectDetails{invoice, folder}},
+		{`path = "photos/2024/"`, []storage.ObjectDetails{folder}},
+		{`type = folder`, []storage.ObjectDetails{folder}},
+		{`type != folder`, []storage.ObjectDetails{invoice, photo}},
+		{`size>10MB`, []storage.ObjectDetails{photo}},
+		{`size <= 200KB`, []storage.ObjectDetails{invoice, folder}},
+		{`size = 0.5KB`, []storage.ObjectDetails{}},
+		{`modified <= 2025-01-31`, []storage.ObjectDetails{invoice}},
+		{`modified > 2025-01-31`, []storage.ObjectDetails{photo, folder}},
+		{`modified = 2025-03-01`, []storage.ObjectDetails{photo, folder}},
+		{`modified >= 2025-03-01T12:00:00Z`, []storage.ObjectDetails{photo}},
+
+		// not binds tighter than and, and and tighter than or
+		{`ext = png and size > 10MB or type = folder`, []storage.ObjectDetails{photo, folder}},
+		{`type = folder or ext = png and size < 1MB`, []storage.ObjectDetails{folder}},
+		{`not type = folder and size < 1MB`, []storage.ObjectDetails{invoice}},
+		{`not (type = folder or size < 1MB)`, []storage.ObjectDetails{photo}},
+		{`NOT not ext = pdf`, []storage.ObjectDetails{invoice}},
+	}
+
+	for _, test := range tests {
+		expression, err := Parse(test.filter, nil)
+		if err != nil {
+			t.Errorf("%s: %v", test.filter, err)
+			continue
+		}
+
+		got := []storage.ObjectDetails{}
+		for _, object := range []storage.ObjectDetails{invoice, photo, folder} {
+			if expression.Match(object) {
+				got = append(got, object)
+			}
+		}
+
+		if len(got) != len(test.want) {
+			t.Errorf("%s: matched %d objects, want %d", test.filter, len(got), len(test.want))
+			continue
+		}
+		for i := range got {
+			if got[i].Name != test.want[i].Name {
+				t.Errorf("%s: matched %s, want %s", test.filter, got[i].Name, test.want[i].Name)
+			}
+		}
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// 23:00 UTC on the 31st is already February 1st in Berlin
+	expression, err := Parse(`modified = 2025-02-01`, berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expression.Match(invoice) {
+		t.Error("date is not read in the given location")
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		filter string
+		pos    int
+	}{
+		{``, 0},
+		{`   `, 0},
+		{`size >`, 6},
+		{`colour = red`, 0},
+		{`size ~ 10MB`, 7},
+		{`size > ten`, 7},
+		{`name < a`, 7},
+		{`type = link`, 7},
+		{`modified > yesterday`, 11},
+		{`ext in png`, 7},
+		{`ext in (png jpg)`, 12},
+		{`(ext = png`, 10},
+		{`ext = png)`, 9},
+		{`ext = png size > 1`, 10},
+		{`name = "unterminated`, 7},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.filter, nil)
+
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: err = %v, want a SyntaxError", test.filter, err)
+			continue
+		}
+		if syntaxErr.Pos != test.pos {
+			t.Errorf("%q: error at %d (%s), want %d", test.filter, syntaxErr.Pos, syntaxErr.Msg, test.pos)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenOpen
+	tokenClose
+	tokenComma
+)
+
+func (kind tokenKind) String() string {
+	switch kind {
+	case tokenEOF:
+		return "end of filter"
+	case tokenWord:
+		return "word"
+	case tokenString:
+		return "string"
+	case tokenOperator:
+		return "operator"
+	case tokenOpen:
+		return `"("`
+	case tokenClose:
+		return `")"`
+	case tokenComma:
+		return `","`
+	}
+	return "token"
+}
+
+// token is a lexical token. pos is the byte offset of its first character in
+// the filter, which is what syntax errors report.
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenWord, tokenOperator:
+		return fmt.Sprintf("%q", t.text)
+	case tokenString:
+		return fmt.Sprintf("string %q", t.text)
+	}
+	return t.kind.String()
+}
+
+// keyword reports whether the token is the bare, case insensitive word w.
+func (t token) keyword(w string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, w)
+}
+
+// operators, longest first so that "<=" is not read as "<"
+var operators = []string{"!=", "<=", ">=", "!~", "=", "<", ">", "~"}
+
+// lex splits a filter into tokens. Words run until whitespace, a parenthesis,
+// a comma, a quote or an operator, so "size>10MB" needs no spaces. Strings
+// are double quoted and may escape a quote or backslash with a backslash.
+func lex(input string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(input); {
+		r, width := utf8.DecodeRuneInString(input[i:])
+
+		switch {
+		case unicode.IsSpace(r):
+			i += width
+			continue
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "(", pos: i})
+			i++
+			continue
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")", pos: i})
+			i++
+			continue
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+			continue
+		case r == '"':
+			text, end, err := lexString(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: i})
+			i = end
+			continue
+		}
+
+		if operator := operatorAt(input, i); operator != "" {
+			tokens = append(tokens, token{kind: tokenOperator, text: operator, pos: i})
+			i += len(operator)
+			continue
+		}
+
+		start := i
+		for i < len(input) {
+			r, width := utf8.DecodeRuneInString(input[i:])
+			if unicode.IsSpace(r) || strings.ContainsRune(`(),"`, r) || operatorAt(input, i) != "" {
+				break
+			}
+			i += width
+		}
+		tokens = append(tokens, token{kind: tokenWord, text: input[start:i], pos: start})
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(input)}), nil
+}
+
+func operatorAt(input string, i int) string {
+	for _, operator := range operators {
+		if strings.HasPrefix(input[i:], operator) {
+			return operator
+		}
+	}
+	return ""
+}
+
+// lexString reads the quoted string starting at input[start] and returns its
+// unescaped text and the offset after the closing quote.
+func lexString(input string, start int) (string, int, error) {
+	var text strings.Builder
+
+	for i := start + 1; i < len(input); i++ {
+		switch input[i] {
+		case '"':
+			return text.String(), i + 1, nil
+		case '\\':
+			if i+1 < len(input) && (input[i+1] == '"' || input[i+1] == '\\') {
+				i++
+			}
+		}
+		text.WriteByte(input[i])
+	}
+
+	return "", 0, &SyntaxError{Pos: start, Msg: "unterminated string"}
+}
@@ -0,0 +1,192 @@
+package index
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"mime"
+	"path"
+	"strings"
+)
+
+// Document formats text can be extracted from.
+const (
+	formatPlain = "plain"
+	formatCSV   = "csv"
+	formatJSON  = "json"
+	formatHTML  = "html"
+)
+
+var formatsByExtension = map[string]string{
+	".txt":      formatPlain,
+	".text":     formatPlain,
+	".log":      formatPlain,
+	".md":       formatPlain,
+	".markdown": formatPlain,
+	".csv":      formatCSV,
+	".json":     formatJSON,
+	".html":     formatHTML,
+	".htm":      formatHTML,
+}
+
+var formatsByContentType = map[string]string{
+	"text/plain":       formatPlain,
+	"text/markdown":    formatPlain,
+	"text/csv":         formatCSV,
+	"application/json": formatJSON,
+	"text/html":        formatHTML,
+}
+
+// format returns the document format of an object, or "" if no text can be
+// extracted from it. The extension wins over the content type, which S3
+// often reports as binary/octet-stream.
+func format(key string, contentType string) string {
+	if format, found := formatsByExtension[strings.ToLower(path.Ext(key))]; found {
+		return format
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return formatsByContentType[mediaType]
+}
+
+// Extractable reports whether text can be extracted from an object.
+func Extractable(key string, contentType string) bool {
+	return format(key, contentType) != ""
+}
+
+// Extract returns the text of a document. Markup, JSON syntax and CSV
+// separators are dropped; what is left is meant for indexing and snippets,
+// not for display.
+func Extract(key string, contentType string, r io.Reader) (string, error) {
+	var text strings.Builder
+
+	switch format(key, contentType) {
+	case formatPlain:
+		if _, err := io.Copy(&text, r); err != nil {
+			return "", err
+		}
+
+	case formatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+
+			// a malformed record only loses that record, the reader
+			// continues with the next line
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				continue
+			}
+			if err != nil {
+				return "", err
+			}
+			text.WriteString(strings.Join(record, " "))
+			text.WriteString("\n")
+		}
+
+	case formatJSON:
+		// keys and string values carry the text, numbers and literals are
+		// kept too since IDs are often searched for
+		decoder := json.NewDecoder(r)
+		decoder.UseNumber()
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+
+			switch value := token.(type) {
+			case string:
+				text.WriteString(value)
+				text.WriteString("\n")
+			case json.Number:
+				text.WriteString(value.String())
+				text.WriteString(" ")
+			}
+		}
+
+	case formatHTML:
+		if err := extractHTML(&text, bufio.NewReader(r)); err != nil {
+			return "", err
+		}
+
+	default:
+		return "", errors.New("unsupported document format")
+	}
+
+	return text.String(), nil
+}
+
+// extractHTML writes the text content of an HTML document to text, skipping
+// tags, comments and the contents of script and style elements.
+func extractHTML(text *strings.Builder, r *bufio.Reader) error {
+	var content strings.Builder
+	skip := ""
+
+	flush := func() {
+		if skip == "" {
+			text.WriteString(html.UnescapeString(content.String()))
+		}
+		content.Reset()
+	}
+
+	for {
+		ch, _, err := r.ReadRune()
+		if err == io.EOF {
+			flush()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if ch != '<' {
+			content.WriteRune(ch)
+			continue
+		}
+
+		flush()
+
+		tag, err := r.ReadString('>')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		// comments may contain ">", read on to the end of the comment
+		if strings.HasPrefix(tag, "!--") {
+			for !strings.HasSuffix(tag, "-->") && err == nil {
+				var more string
+				more, err = r.ReadString('>')
+				tag += more
+			}
+		}
+
+		closing := strings.HasPrefix(tag, "/")
+		name := strings.TrimPrefix(tag, "/")
+		if end := strings.IndexAny(name, " \t\r\n/>"); end >= 0 {
+			name = name[:end]
+		}
+		name = strings.ToLower(name)
+
+		switch {
+		case skip == "" && !closing && (name == "script" || name == "style"):
+			skip = name
+		case skip != "" && closing && name == skip:
+			skip = ""
+		}
+
+		// tags separate words
+		text.WriteString(" ")
+	}
+}
@@ -0,0 +1,205 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+)
+
+// textWorkers is the number of documents downloaded and indexed in parallel.
+const textWorkers = 4
+
+// snippetWidth is the length of the snippets returned with search hits.
+const snippetWidth = 200
+
+// textJob is a document waiting to be indexed.
+type textJob struct {
+	bucket      string
+	key         string
+	etag        string
+	contentType string
+}
+
+// textQueue is an unbounded queue of documents to index. Writes must never
+// wait for the indexing to catch up.
+type textQueue struct {
+	mutex  sync.Mutex
+	jobs   []textJob
+	signal chan struct{}
+}
+
+func newTextQueue() *textQueue {
+	return &textQueue{signal: make(chan struct{}, 1)}
+}
+
+func (q *textQueue) push(job textJob) {
+	q.mutex.Lock()
+	q.jobs = append(q.jobs, job)
+	q.mutex.Unlock()
+
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// pop waits for the next job until ctx is done.
+func (q *textQueue) pop(ctx context.Context) (textJob, bool) {
+	for {
+		q.mutex.Lock()
+		if len(q.jobs) > 0 {
+			job := q.jobs[0]
+			q.jobs[0] = textJob{}
+			q.jobs = q.jobs[1:]
+			remaining := len(q.jobs)
+			q.mutex.Unlock()
+
+			// wake up the next worker
+			if remaining > 0 {
+				select {
+				case q.signal <- struct{}{}:
+				default:
+				}
+			}
+
+			return job, true
+		}
+		q.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return textJob{}, false
+		case <-q.signal:
+		}
+	}
+}
+
+func (q *textQueue) len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.jobs)
+}
+
+// TextIndex returns the full text index of a bucket.
+func (i *Indexer) TextIndex(bucket string) (*TextIndex, error) {
+	text, found := i.texts[bucket]
+	if !found {
+		return nil, fmt.Errorf("full text search is not enabled for bucket %q", bucket)
+	}
+
+	return text, nil
+}
+
+// SearchText searches the full text index of a bucket.
+func (i *Indexer) SearchText(ctx context.Context, bucket string, query TextQuery) (*TextResult, error) {
+	text, err := i.TextIndex(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	result := text.Search(query)
+	result.Pending = i.queue.len()
+
+	return result, nil
+}
+
+// queueText queues a document for indexing if text can be extracted from it,
+// and drops it from the text index otherwise.
+func (i *Indexer) queueText(bucket string, entry Entry) {
+	text, found := i.texts[bucket]
+	if !found {
+		return
+	}
+
+	if !Extractable(entry.Key, entry.ContentType) || entry.Size > i.maxTextSize {
+		text.Remove(entry.Key)
+		return
+	}
+
+	i.queue.push(textJob{
+		bucket:      bucket,
+		key:         entry.Key,
+		etag:        entry.ETag,
+		contentType: entry.ContentType,
+	})
+}
+
+// reconcileText brings the text index of a bucket in line with its metadata
+// index: new and changed documents are queued, vanished ones removed.
+func (i *Indexer) reconcileText(bucket string) {
+	text, found := i.texts[bucket]
+	if !found {
+		return
+	}
+
+	idx := i.indexes[bucket]
+
+	for _, key := range text.Keys() {
+		if _, found := idx.Get(key); !found {
+			text.Remove(key)
+		}
+	}
+
+	queued := 0
+	idx.Each(func(entry Entry) {
+		if etag, found := text.ETag(entry.Key); found && etag == entry.ETag {
+			return
+		}
+
+		if Extractable(entry.Key, entry.ContentType) && entry.Size <= i.maxTextSize {
+			i.queueText(bucket, entry)
+			queued++
+		}
+	})
+
+	if queued > 0 {
+		log.Printf("Queued %d documents of bucket %s for full text indexing", queued, bucket)
+	}
+}
+
+// indexText indexes queued documents until ctx is done.
+func (i *Indexer) indexText(ctx context.Context) {
+	for {
+		job, ok := i.queue.pop(ctx)
+		if !ok {
+			return
+		}
+
+		// skip documents that were changed or deleted since they were
+		// queued, the newer version is queued as well
+		entry, found := i.indexes[job.bucket].Get(job.key)
+		if !found || entry.ETag != job.etag {
+			continue
+		}
+
+		if err := i.extractText(ctx, job); err != nil {
+			log.Printf("Failed to index text of %s/%s: %s", job.bucket, job.key, err)
+			i.texts[job.bucket].Remove(job.key)
+		}
+	}
+}
+
+func (i *Indexer) extractText(ctx context.Context, job textJob) error {
+	client, err := i.buckets.Client(job.bucket)
+	if err != nil {
+		return err
+	}
+
+	body, err := client.GetFile(ctx, job.key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	content, err := Extract(job.key, job.contentType, io.LimitReader(body, i.maxTextSize))
+	if err != nil {
+		return err
+	}
+
+	i.texts[job.bucket].Add(job.key, job.etag, content)
+
+	return nil
+}
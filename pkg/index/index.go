@@ -0,0 +1,449 @@
+package index
+
+import (
+	"encoding/gob"
+	"io"
+	"mime"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is the indexed metadata of one object.
+type Entry struct {
+	Key          string            `json:"key"`
+	Size         int64             `json:"size"`
+	LastModified time.Time         `json:"lastModified"`
+	ETag         string            `json:"etag,omitempty"`
+	ContentType  string            `json:"contentType,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+
+	// folded is the key in lower case. strings.ToLower returns keys that are
+	// already in lower case as they are, so those don't take extra memory.
+	folded string
+}
+
+// Query selects entries from an Index. Zero values don't restrict the search.
+type Query struct {
+	// Name is matched case insensitively against any part of the key.
+	Name string
+
+	// Prefix restricts the search to keys below a folder.
+	Prefix string
+
+	// Extensions are file extensions without the dot, in lower case.
+	Extensions []string
+
+	// MinSize and MaxSize are inclusive bounds in bytes. A negative MaxSize
+	// means unlimited.
+	MinSize int64
+	MaxSize int64
+
+	// ModifiedAfter and ModifiedBefore bound the last modification time.
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+
+	// ContentType matches content types starting with it, e.g. "image/".
+	ContentType string
+
+	// Tags must all be present on an entry with the given values.
+	Tags map[string]string
+
+	Offset int
+	Limit  int
+}
+
+// Result is one page of the entries matching a Query, ordered by key.
+type Result struct {
+	Entries []Entry `json:"entries"`
+	Total   int     `json:"total"`
+
+	// CrawledAt and Rebuilding tell how current the index is
+	CrawledAt  time.Time `json:"crawledAt"`
+	Rebuilding bool      `json:"rebuilding"`
+}
+
+// change is a write that happened while the index was being rebuilt.
+type change struct {
+	entry   Entry
+	removed bool
+}
+
+// Index holds the metadata of all objects of one bucket in memory. Searches
+// scan all entries in parallel, which takes well under a second for tens of
+// millions of objects and needs no secondary structures to keep in sync.
+type Index struct {
+	mutex     sync.RWMutex
+	entries   []Entry
+	positions map[string]int
+
+	// content types are shared between entries instead of being stored for
+	// every object
+	contentTypes map[string]string
+
+	crawledAt  time.Time
+	rebuilding bool
+	journal    []change
+
+	// version counts the changes, saved is the version of the last snapshot
+	version uint64
+	saved   uint64
+}
+
+// snapshot is the on-disk format of an Index.
+type snapshot struct {
+	CrawledAt time.Time
+	Entries   []Entry
+}
+
+// New creates an empty Index.
+func New() *Index {
+	return &Index{
+		positions:    make(map[string]int),
+		contentTypes: make(map[string]string),
+	}
+}
+
+// Put adds or replaces the entry for a key.
+func (idx *Index) Put(entry Entry) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.put(entry)
+	if idx.rebuilding {
+		idx.journal = append(idx.journal, change{entry: entry})
+	}
+}
+
+// Remove drops the entry for a key, if there is one.
+func (idx *Index) Remove(key string) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.remove(key)
+	if idx.rebuilding {
+		idx.journal = append(idx.journal, change{entry: Entry{Key: key}, removed: true})
+	}
+}
+
+// Get returns the entry for a key.
+func (idx *Index) Get(key string) (Entry, bool) {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	i, found := idx.positions[key]
+	if !found {
+		return Entry{}, false
+	}
+
+	return idx.entries[i], true
+}
+
+// Each calls fn for every entry. fn must not modify the index.
+func (idx *Index) Each(fn func(Entry)) {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	for _, entry := range idx.entries {
+		fn(entry)
+	}
+}
+
+// Len returns the number of indexed objects.
+func (idx *Index) Len() int {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	return len(idx.entries)
+}
+
+// CrawledAt returns when the last complete crawl finished.
+func (idx *Index) CrawledAt() time.Time {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	return idx.crawledAt
+}
+
+// Rebuilding reports whether a crawl is in progress.
+func (idx *Index) Rebuilding() bool {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	return idx.rebuilding
+}
+
+// Search returns the entries matching query.
+func (idx *Index) Search(query Query) *Result {
+	if query.Limit <= 0 {
+		query.Limit = 100
+	}
+
+	name := strings.ToLower(query.Name)
+	extensions := make(map[string]bool, len(query.Extensions))
+	for _, ext := range query.Extensions {
+		extensions[ext] = true
+	}
+
+	matches := func(entry *Entry) bool {
+		if !strings.HasPrefix(entry.Key, query.Prefix) {
+			return false
+		}
+		if name != "" && !strings.Contains(entry.folded, name) {
+			return false
+		}
+		if len(extensions) > 0 && !extensions[extension(entry.Key)] {
+			return false
+		}
+		if entry.Size < query.MinSize || (query.MaxSize >= 0 && entry.Size > query.MaxSize) {
+			return false
+		}
+		if !query.ModifiedAfter.IsZero() && entry.LastModified.Before(query.ModifiedAfter) {
+			return false
+		}
+		if !query.ModifiedBefore.IsZero() && entry.LastModified.After(query.ModifiedBefore) {
+			return false
+		}
+		if query.ContentType != "" && !strings.HasPrefix(entry.ContentType, query.ContentType) {
+			return false
+		}
+		for key, value := range query.Tags {
+			if tag, found := entry.Tags[key]; !found || tag != value {
+				return false
+			}
+		}
+		return true
+	}
+
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	// Every worker keeps the first offset+limit matches by key of its share
+	// of the entries, so memory stays bounded no matter how many match
+	keep := query.Offset + query.Limit
+	workers := runtime.GOMAXPROCS(0)
+	chunk := (len(idx.entries) + workers - 1) / workers
+	found := make([][]*Entry, workers)
+	totals := make([]int, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w*chunk < len(idx.entries); w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			end := (w + 1) * chunk
+			if end > len(idx.entries) {
+				end = len(idx.entries)
+			}
+
+			for i := w * chunk; i < end; i++ {
+				entry := &idx.entries[i]
+				if !matches(entry) {
+					continue
+				}
+
+				totals[w]++
+				found[w] = append(found[w], entry)
+				if len(found[w]) >= 2*keep {
+					found[w] = firstByKey(found[w], keep)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	result := &Result{
+		Entries:    []Entry{},
+		CrawledAt:  idx.crawledAt,
+		Rebuilding: idx.rebuilding,
+	}
+	var all []*Entry
+	for w := range found {
+		result.Total += totals[w]
+		all = append(all, found[w]...)
+	}
+
+	all = firstByKey(all, keep)
+	if query.Offset < len(all) {
+		for _, entry := range all[query.Offset:] {
+			result.Entries = append(result.Entries, *entry)
+		}
+	}
+
+	return result
+}
+
+// Snapshot writes a snapshot of the index. Searches can continue while the
+// snapshot is written, writes have to wait.
+func (idx *Index) Snapshot(w io.Writer) error {
+	idx.mutex.RLock()
+	version := idx.version
+	err := gob.NewEncoder(w).Encode(&snapshot{
+		CrawledAt: idx.crawledAt,
+		Entries:   idx.entries,
+	})
+	idx.mutex.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	idx.mutex.Lock()
+	idx.saved = version
+	idx.mutex.Unlock()
+
+	return nil
+}
+
+// Restore replaces the contents of the index with a snapshot.
+func (idx *Index) Restore(r io.Reader) error {
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return err
+	}
+
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.replace(snap.Entries)
+	idx.crawledAt = snap.CrawledAt
+	idx.saved = idx.version
+
+	return nil
+}
+
+// Dirty reports whether the index changed since the last snapshot.
+func (idx *Index) Dirty() bool {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	return idx.version != idx.saved
+}
+
+// beginRebuild starts recording writes so they can be replayed on top of the
+// results of a crawl. It returns false if a rebuild is already running.
+func (idx *Index) beginRebuild() bool {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	if idx.rebuilding {
+		return false
+	}
+
+	idx.rebuilding = true
+	idx.journal = nil
+
+	return true
+}
+
+// finishRebuild replaces the contents of the index with the crawled entries
+// and replays the writes that happened during the crawl.
+func (idx *Index) finishRebuild(entries []Entry) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.replace(entries)
+	for _, change := range idx.journal {
+		if change.removed {
+			idx.remove(change.entry.Key)
+		} else {
+			idx.put(change.entry)
+		}
+	}
+
+	idx.crawledAt = time.Now().UTC()
+	idx.rebuilding = false
+	idx.journal = nil
+}
+
+// abortRebuild stops recording writes after a failed crawl.
+func (idx *Index) abortRebuild() {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.rebuilding = false
+	idx.journal = nil
+}
+
+func (idx *Index) put(entry Entry) {
+	entry.ContentType = idx.internContentType(entry.Key, entry.ContentType)
+	entry.folded = strings.ToLower(entry.Key)
+
+	if i, found := idx.positions[entry.Key]; found {
+		idx.entries[i] = entry
+	} else {
+		idx.positions[entry.Key] = len(idx.entries)
+		idx.entries = append(idx.entries, entry)
+	}
+
+	idx.version++
+}
+
+func (idx *Index) remove(key string) {
+	i, found := idx.positions[key]
+	if !found {
+		return
+	}
+
+	// move the last entry into the gap, entries are not kept in any order
+	last := len(idx.entries) - 1
+	if i != last {
+		idx.entries[i] = idx.entries[last]
+		idx.positions[idx.entries[i].Key] = i
+	}
+
+	idx.entries[last] = Entry{}
+	idx.entries = idx.entries[:last]
+	delete(idx.positions, key)
+
+	idx.version++
+}
+
+func (idx *Index) replace(entries []Entry) {
+	idx.entries = entries
+	idx.positions = make(map[string]int, len(entries))
+	for i := range idx.entries {
+		idx.entries[i].ContentType = idx.internContentType(idx.entries[i].Key, idx.entries[i].ContentType)
+		idx.entries[i].folded = strings.ToLower(idx.entries[i].Key)
+		idx.positions[idx.entries[i].Key] = i
+	}
+
+	idx.version++
+}
+
+// internContentType returns a shared copy of the content type of an object,
+// guessing it from the extension when the backend didn't report one.
+func (idx *Index) internContentType(key string, contentType string) string {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+
+	if shared, found := idx.contentTypes[contentType]; found {
+		return shared
+	}
+
+	idx.contentTypes[contentType] = contentType
+	return contentType
+}
+
+// firstByKey returns the first n entries in key order.
+func firstByKey(entries []*Entry, n int) []*Entry {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+
+	return entries
+}
+
+// extension returns the lower case extension of a key without the dot.
+func extension(key string) string {
+	return strings.ToLower(strings.TrimPrefix(path.Ext(key), "."))
+}
@@ -0,0 +1,394 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"file-management-service/config"
+	"file-management-service/pkg/storage"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrRebuilding is returned when a rebuild is requested while one is running.
+var ErrRebuilding = errors.New("index is already being rebuilt")
+
+// Indexer keeps an Index for every bucket of a Registry. The indexes are
+// filled by crawling the buckets, kept current by observing the writes made
+// through the service, and snapshotted to disk so a restart doesn't need a
+// new crawl. With full text search enabled, the text of the documents found
+// is extracted in the background and kept in a TextIndex per bucket.
+type Indexer struct {
+	ctx      context.Context
+	dir      string
+	interval time.Duration
+	buckets  *storage.Registry
+	indexes  map[string]*Index
+	tags     bool
+	wg       sync.WaitGroup
+
+	texts       map[string]*TextIndex
+	queue       *textQueue
+	maxTextSize int64
+
+	// saving serializes writing snapshots
+	saving sync.Mutex
+}
+
+// Indexer must be notified about the writes made through the clients.
+var _ storage.Observer = (*Indexer)(nil)
+
+// NewIndexer creates an Indexer for all buckets of the registry and registers
+// it as their observer.
+func NewIndexer(config *config.Config, buckets *storage.Registry) (*Indexer, error) {
+	if err := os.MkdirAll(config.IndexDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	indexer := &Indexer{
+		ctx:      context.Background(),
+		dir:      config.IndexDir,
+		interval: time.Duration(config.IndexSnapshotMinutes) * time.Minute,
+		buckets:  buckets,
+		indexes:  make(map[string]*Index),
+		tags:     config.IndexTags,
+
+		texts:       make(map[string]*TextIndex),
+		queue:       newTextQueue(),
+		maxTextSize: int64(config.FullTextMaxSizeMB) * 1024 * 1024,
+	}
+
+	for _, name := range buckets.Names() {
+		client, err := buckets.Client(name)
+		if err != nil {
+			return nil, err
+		}
+
+		indexer.indexes[name] = New()
+		if config.FullTextEnabled {
+			indexer.texts[name] = NewTextIndex()
+		}
+
+		client.Observe(indexer)
+	}
+
+	return indexer, nil
+}
+
+// Start loads the snapshots of all indexes and crawls the buckets that don't
+// have one yet. Snapshots are written periodically and crawls run until ctx
+// is done.
+func (i *Indexer) Start(ctx context.Context) {
+	i.ctx = ctx
+
+	for n := 0; n < textWorkers && len(i.texts) > 0; n++ {
+		i.wg.Add(1)
+		go func() {
+			defer i.wg.Done()
+			i.indexText(ctx)
+		}()
+	}
+
+	for name, idx := range i.indexes {
+		if text, found := i.texts[name]; found {
+			err := i.load(i.snapshotPath(name, ".text"), text)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("Failed to load text index of bucket %s, rebuilding it: %s", name, err)
+			}
+		}
+
+		err := i.load(i.snapshotPath(name, ".index"), idx)
+		if err == nil {
+			log.Printf("Loaded index of bucket %s with %d objects", name, idx.Len())
+			i.reconcileText(name)
+			continue
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Failed to load index of bucket %s, rebuilding it: %s", name, err)
+		}
+
+		if err := i.Rebuild(name); err != nil {
+			log.Printf("Failed to rebuild index of bucket %s: %s", name, err)
+		}
+	}
+
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+
+		ticker := time.NewTicker(i.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				i.Save()
+			}
+		}
+	}()
+}
+
+// Close waits for running crawls and snapshots and writes the final snapshots.
+// ctx must be done before Close is called.
+func (i *Indexer) Close() {
+	i.wg.Wait()
+	i.Save()
+}
+
+// Index returns the index of a bucket.
+func (i *Indexer) Index(bucket string) (*Index, error) {
+	idx, found := i.indexes[bucket]
+	if !found {
+		return nil, fmt.Errorf("bucket %q is not indexed", bucket)
+	}
+
+	return idx, nil
+}
+
+// Rebuild crawls a bucket in the background and replaces its index with the
+// result. It is needed to pick up changes made without going through the
+// service, e.g. direct uploads with presigned URLs.
+func (i *Indexer) Rebuild(bucket string) error {
+	idx, err := i.Index(bucket)
+	if err != nil {
+		return err
+	}
+
+	client, err := i.buckets.Client(bucket)
+	if err != nil {
+		return err
+	}
+
+	if !idx.beginRebuild() {
+		return ErrRebuilding
+	}
+
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+
+		started := time.Now()
+		entries, err := crawl(i.ctx, client, i.tags)
+		if err != nil {
+			idx.abortRebuild()
+			log.Printf("Failed to crawl bucket %s: %s", bucket, err)
+			return
+		}
+
+		idx.finishRebuild(entries)
+		log.Printf("Indexed %d objects of bucket %s in %s", len(entries), bucket, time.Since(started).Round(time.Second))
+
+		if err := i.save(i.snapshotPath(bucket, ".index"), idx); err != nil {
+			log.Printf("Failed to save index of bucket %s: %s", bucket, err)
+		}
+
+		i.reconcileText(bucket)
+	}()
+
+	return nil
+}
+
+// Save writes snapshots of all indexes that changed since the last one.
+func (i *Indexer) Save() {
+	for name, idx := range i.indexes {
+		if !idx.Dirty() || idx.Rebuilding() {
+			continue
+		}
+
+		if err := i.save(i.snapshotPath(name, ".index"), idx); err != nil {
+			log.Printf("Failed to save index of bucket %s: %s", name, err)
+		}
+	}
+
+	for name, text := range i.texts {
+		if !text.Dirty() {
+			continue
+		}
+
+		if err := i.save(i.snapshotPath(name, ".text"), text); err != nil {
+			log.Printf("Failed to save text index of bucket %s: %s", name, err)
+		}
+	}
+}
+
+// ObjectStored updates the index after an object was written.
+func (i *Indexer) ObjectStored(bucket string, info storage.ObjectInfo) {
+	idx, found := i.indexes[bucket]
+	if !found {
+		return
+	}
+
+	entry := Entry{
+		Key:          info.Key,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+		ETag:         info.ETag,
+		ContentType:  info.ContentType,
+		Tags:         info.Tags,
+	}
+
+	idx.Put(entry)
+	i.queueText(bucket, entry)
+}
+
+// ObjectRemoved updates the index after an object was deleted.
+func (i *Indexer) ObjectRemoved(bucket string, key string) {
+	if idx, found := i.indexes[bucket]; found {
+		idx.Remove(key)
+	}
+
+	if text, found := i.texts[bucket]; found {
+		text.Remove(key)
+	}
+}
+
+// tagWorkers bounds the tag requests a crawl sends at the same time.
+const tagWorkers = 16
+
+// crawl lists all objects of a bucket. With tags, the tags of every object
+// are fetched as well on backends that have them, which takes one request
+// per object.
+func crawl(ctx context.Context, client *storage.Client, tags bool) ([]Entry, error) {
+	entries := []Entry{}
+	token := ""
+
+	tagReader, _ := client.Backend().(storage.TagReader)
+	if !tags {
+		tagReader = nil
+	}
+
+	for {
+		resp, err := client.List(ctx, storage.ListInput{
+			ContinuationToken: token,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		page := make([]Entry, len(resp.Objects))
+		for n, obj := range resp.Objects {
+			page[n] = Entry{
+				Key:          obj.Key,
+				Size:         obj.Size,
+				LastModified: obj.LastModified,
+				ETag:         obj.ETag,
+				ContentType:  obj.ContentType,
+			}
+		}
+
+		// objects whose tags cannot be read are indexed without them, tags
+		// are not worth failing the whole crawl for
+		if tagReader != nil {
+			if failed, err := fetchTags(ctx, tagReader, page); failed > 0 && ctx.Err() == nil {
+				log.Printf("Failed to get the tags of %d objects of bucket %s: %s", failed, client.Name(), err)
+			}
+		}
+
+		entries = append(entries, page...)
+
+		if len(entries)/100000 != (len(entries)-len(resp.Objects))/100000 {
+			log.Printf("Crawled %d objects of bucket %s", len(entries), client.Name())
+		}
+
+		if !resp.IsTruncated {
+			return entries, nil
+		}
+		token = resp.NextContinuationToken
+	}
+}
+
+// fetchTags sets the tags of entries, tagWorkers requests at a time. It
+// returns the number of entries whose tags could not be read and the first
+// error.
+func fetchTags(ctx context.Context, reader storage.TagReader, entries []Entry) (int, error) {
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		failed   int
+		firstErr error
+	)
+
+	slots := make(chan struct{}, tagWorkers)
+
+	for n := range entries {
+		slots <- struct{}{}
+
+		wg.Add(1)
+		go func(entry *Entry) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			tags, err := reader.GetTags(ctx, entry.Key)
+			if errors.Is(err, storage.ErrNotFound) {
+				return // deleted since it was listed
+			}
+			if err != nil {
+				mutex.Lock()
+				failed++
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to get the tags of %s: %w", entry.Key, err)
+				}
+				mutex.Unlock()
+				return
+			}
+
+			entry.Tags = tags
+		}(&entries[n])
+	}
+
+	wg.Wait()
+
+	return failed, firstErr
+}
+
+// snapshotter is implemented by the indexes that can be saved to disk.
+type snapshotter interface {
+	Snapshot(w io.Writer) error
+	Restore(r io.Reader) error
+}
+
+func (i *Indexer) load(path string, index snapshotter) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return index.Restore(f)
+}
+
+// save atomically replaces the snapshot of an index.
+func (i *Indexer) save(path string, index snapshotter) error {
+	i.saving.Lock()
+	defer i.saving.Unlock()
+
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	if err := index.Snapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func (i *Indexer) snapshotPath(bucket string, extension string) string {
+	return filepath.Join(i.dir, url.PathEscape(bucket)+extension)
+}
@@ -0,0 +1,486 @@
+package index
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/gob"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BM25 parameters, the usual defaults.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// snippetTextSize is how much of the text of a document is kept for
+// snippets. Matches further into a document get a snippet of its beginning.
+const snippetTextSize = 16 << 10
+
+// maxTermLength drops tokens that are unlikely to be searched for, like
+// base64 blobs, before they bloat the index.
+const maxTermLength = 64
+
+// TextQuery selects documents from a TextIndex.
+type TextQuery struct {
+	// Text is split into terms the same way documents are.
+	Text string
+
+	// Any matches documents containing any of the terms instead of all.
+	Any bool
+
+	// Prefix restricts the search to keys below a folder. Unless Recursive
+	// is set only the folder's direct children match, like with /list.
+	Prefix    string
+	Recursive bool
+
+	Offset int
+	Limit  int
+}
+
+// Hit is a document matching a TextQuery.
+type Hit struct {
+	Key     string  `json:"key"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet,omitempty"`
+
+	id uint32
+}
+
+// TextResult is one page of the documents matching a TextQuery, best first.
+type TextResult struct {
+	Hits  []Hit    `json:"hits"`
+	Total int      `json:"total"`
+	Terms []string `json:"terms"`
+
+	// Pending is the number of documents waiting to be indexed
+	Pending int `json:"pending"`
+}
+
+// document is an indexed document. Terms lists its distinct terms so it can
+// be removed from the postings again, Text holds the start of its text,
+// compressed, for snippets.
+type document struct {
+	Key    string
+	ETag   string
+	Length int
+	Terms  []string
+	Text   []byte
+}
+
+// TextIndex is an inverted index over the text of the documents in a bucket.
+type TextIndex struct {
+	mutex       sync.RWMutex
+	nextID      uint32
+	documents   map[uint32]*document
+	ids         map[string]uint32
+	postings    map[string]map[uint32]uint32 // term -> document -> frequency
+	totalLength int
+
+	version uint64
+	saved   uint64
+}
+
+// textSnapshot is the on-disk format of a TextIndex.
+type textSnapshot struct {
+	NextID    uint32
+	Documents map[uint32]*document
+	Postings  map[string]map[uint32]uint32
+}
+
+// NewTextIndex creates an empty TextIndex.
+func NewTextIndex() *TextIndex {
+	return &TextIndex{
+		documents: make(map[uint32]*document),
+		ids:       make(map[string]uint32),
+		postings:  make(map[string]map[uint32]uint32),
+	}
+}
+
+// Add indexes the text of a document, replacing an older version.
+func (t *TextIndex) Add(key string, etag string, text string) {
+	frequencies := make(map[string]uint32)
+	length := 0
+	for _, term := range Terms(text) {
+		frequencies[term]++
+		length++
+	}
+
+	doc := &document{
+		Key:    key,
+		ETag:   etag,
+		Length: length,
+		Terms:  make([]string, 0, len(frequencies)),
+		Text:   compressText(text),
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.remove(key)
+
+	id := t.nextID
+	t.nextID++
+
+	for term, frequency := range frequencies {
+		postings, found := t.postings[term]
+		if !found {
+			postings = make(map[uint32]uint32)
+			t.postings[term] = postings
+		}
+		postings[id] = frequency
+		doc.Terms = append(doc.Terms, term)
+	}
+
+	t.documents[id] = doc
+	t.ids[key] = id
+	t.totalLength += length
+	t.version++
+}
+
+// Remove drops a document from the index.
+func (t *TextIndex) Remove(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.remove(key)
+}
+
+// ETag returns the ETag of the indexed version of a document.
+func (t *TextIndex) ETag(key string) (string, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	id, found := t.ids[key]
+	if !found {
+		return "", false
+	}
+
+	return t.documents[id].ETag, true
+}
+
+// Keys returns the keys of all indexed documents.
+func (t *TextIndex) Keys() []string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	keys := make([]string, 0, len(t.ids))
+	for key := range t.ids {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Search ranks the documents matching query with BM25 and returns them with a
+// snippet around the first match.
+func (t *TextIndex) Search(query TextQuery) *TextResult {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	terms := uniqueTerms(Terms(query.Text))
+	result := &TextResult{Hits: []Hit{}, Terms: terms}
+	if len(terms) == 0 {
+		return result
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	count := float64(len(t.documents))
+	averageLength := float64(t.totalLength) / math.Max(count, 1)
+
+	scores := make(map[uint32]float64)
+	matched := make(map[uint32]int)
+
+	for _, term := range terms {
+		postings := t.postings[term]
+		idf := math.Log(1 + (count-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+
+		for id, frequency := range postings {
+			length := float64(t.documents[id].Length)
+			tf := float64(frequency)
+			scores[id] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*length/averageLength))
+			matched[id]++
+		}
+	}
+
+	hits := []Hit{}
+	for id, score := range scores {
+		if !query.Any && matched[id] < len(terms) {
+			continue
+		}
+
+		key := t.documents[id].Key
+		if !strings.HasPrefix(key, query.Prefix) {
+			continue
+		}
+		if !query.Recursive && strings.Contains(key[len(query.Prefix):], "/") {
+			continue
+		}
+
+		hits = append(hits, Hit{Key: key, Score: math.Round(score*1000) / 1000, id: id})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Key < hits[j].Key
+	})
+
+	result.Total = len(hits)
+	if query.Offset < len(hits) {
+		hits = hits[query.Offset:]
+		if len(hits) > query.Limit {
+			hits = hits[:query.Limit]
+		}
+
+		// snippets are only made for the page that is returned
+		for n := range hits {
+			hits[n].Snippet = Snippet(decompressText(t.documents[hits[n].id].Text), terms, snippetWidth)
+		}
+		result.Hits = hits
+	}
+
+	return result
+}
+
+// Snapshot writes a snapshot of the index.
+func (t *TextIndex) Snapshot(w io.Writer) error {
+	t.mutex.RLock()
+	version := t.version
+	err := gob.NewEncoder(w).Encode(&textSnapshot{
+		NextID:    t.nextID,
+		Documents: t.documents,
+		Postings:  t.postings,
+	})
+	t.mutex.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	t.saved = version
+	t.mutex.Unlock()
+
+	return nil
+}
+
+// Restore replaces the contents of the index with a snapshot.
+func (t *TextIndex) Restore(r io.Reader) error {
+	var snap textSnapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.nextID = snap.NextID
+	t.documents = snap.Documents
+	t.postings = snap.Postings
+	t.ids = make(map[string]uint32, len(snap.Documents))
+	t.totalLength = 0
+
+	if t.documents == nil {
+		t.documents = make(map[uint32]*document)
+	}
+	if t.postings == nil {
+		t.postings = make(map[string]map[uint32]uint32)
+	}
+
+	for id, doc := range t.documents {
+		t.ids[doc.Key] = id
+		t.totalLength += doc.Length
+
+		// snapshots from before the text was kept have no snippets, forget
+		// the ETag so the document is indexed again
+		if doc.Text == nil {
+			doc.ETag = ""
+		}
+	}
+
+	t.saved = t.version
+
+	return nil
+}
+
+// Dirty reports whether the index changed since the last snapshot.
+func (t *TextIndex) Dirty() bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.version != t.saved
+}
+
+func (t *TextIndex) remove(key string) {
+	id, found := t.ids[key]
+	if !found {
+		return
+	}
+
+	doc := t.documents[id]
+	for _, term := range doc.Terms {
+		postings := t.postings[term]
+		delete(postings, id)
+		if len(postings) == 0 {
+			delete(t.postings, term)
+		}
+	}
+
+	delete(t.documents, id)
+	delete(t.ids, key)
+	t.totalLength -= doc.Length
+	t.version++
+}
+
+// Terms splits text into lower case words made of letters and digits.
+func Terms(text string) []string {
+	terms := []string{}
+
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(word) <= maxTermLength {
+			terms = append(terms, strings.ToLower(word))
+		}
+	}
+
+	return terms
+}
+
+// Snippet returns the part of text around the first occurrence of any of the
+// terms, cut at word boundaries and about width characters long.
+func Snippet(text string, terms []string, width int) string {
+	// offsets in lower are used on text, which only works while lowering
+	// doesn't change the length, as it does for a few rare characters
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		lower = text
+	}
+
+	// find the earliest term that occurs as a whole word
+	start := -1
+	for _, term := range terms {
+		for offset := 0; offset < len(lower); {
+			i := strings.Index(lower[offset:], term)
+			if i < 0 {
+				break
+			}
+			i += offset
+
+			before, _ := utf8.DecodeLastRuneInString(lower[:i])
+			after, _ := utf8.DecodeRuneInString(lower[i+len(term):])
+			if !isWordRune(before) && !isWordRune(after) {
+				if start < 0 || i < start {
+					start = i
+				}
+				break
+			}
+			offset = i + len(term)
+		}
+	}
+
+	if start < 0 {
+		start = 0
+	}
+
+	// center the match and extend to word boundaries
+	from := start - width/2
+	if from < 0 {
+		from = 0
+	}
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	if from > 0 {
+		if space := strings.IndexFunc(text[from:start], unicode.IsSpace); space >= 0 {
+			from += space + 1
+		}
+	}
+
+	to := from + width
+	if to >= len(text) {
+		to = len(text)
+	} else {
+		for to > start && !utf8.RuneStart(text[to]) {
+			to--
+		}
+		if space := strings.LastIndexFunc(text[start:to], unicode.IsSpace); space > 0 {
+			to = start + space
+		}
+	}
+
+	snippet := strings.Join(strings.Fields(text[from:to]), " ")
+	if from > 0 {
+		snippet = "…" + snippet
+	}
+	if to < len(text) {
+		snippet += "…"
+	}
+
+	return snippet
+}
+
+// compressText keeps the first snippetTextSize bytes of text, with runs of
+// white space collapsed, and compresses them.
+func compressText(text string) []byte {
+	var collapsed strings.Builder
+	for _, word := range strings.Fields(text) {
+		if collapsed.Len()+len(word) >= snippetTextSize {
+			break
+		}
+		if collapsed.Len() > 0 {
+			collapsed.WriteByte(' ')
+		}
+		collapsed.WriteString(word)
+	}
+
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write([]byte(collapsed.String()))
+	w.Close()
+
+	return buf.Bytes()
+}
+
+// decompressText returns the text kept by compressText, or "" for documents
+// indexed before the text was kept.
+func decompressText(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	text, err := io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return ""
+	}
+
+	return string(text)
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	unique := []string{}
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,157 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"file-management-service/pkg/storage"
+)
+
+// TypeCopyFolder copies every object below a folder to another folder,
+// possibly in another bucket.
+const TypeCopyFolder = "copy-folder"
+
+// CopyFolder starts a job copying the folder source with everything below it
+// to destination in targetBucket, which is bucket itself when empty. policy
+// is applied to every object whose destination already exists, and options
+// to every copy.
+func (m *Manager) CopyFolder(ctx context.Context, bucket string, source string, targetBucket string, destination string, policy storage.ConflictPolicy, options storage.CopyOptions) (*Job, error) {
+	client, err := m.buckets.Client(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	target := client
+	if targetBucket != "" {
+		target, err = m.buckets.Client(targetBucket)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	source, destination, err = folderPaths(source, destination)
+	if err != nil {
+		return nil, err
+	}
+
+	// the copies would be found by the walk and copied again
+	if target == client && strings.HasPrefix(destination, source) {
+		return nil, fmt.Errorf("cannot copy %s into itself", source)
+	}
+
+	if err := checkFolder(ctx, client, source); err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		Type:        TypeCopyFolder,
+		Bucket:      client.Name(),
+		Source:      source,
+		Destination: destination,
+		Conflict:    policy,
+		Options:     &options,
+	}
+	if target != client {
+		job.TargetBucket = target.Name()
+	}
+
+	return m.create(job)
+}
+
+// copyFolder runs a TypeCopyFolder job. Files are copied concurrently and
+// each copy is verified. The keys of the files that were copied are recorded
+// with the job, so a resumed job skips them and continues where it stopped.
+func (m *Manager) copyFolder(client *storage.Client, job *Job) error {
+	ctx := m.ctx
+
+	target := client
+	if job.TargetBucket != "" {
+		var err error
+		target, err = m.buckets.Client(job.TargetBucket)
+		if err != nil {
+			return err
+		}
+	}
+
+	copied, err := m.store.Recorded(job.ID)
+	if err != nil {
+		return err
+	}
+
+	// every run visits every file again, the copied ones are counted when
+	// they are skipped
+	m.update(job, func() {
+		job.ProcessedObjects = 0
+		job.ProcessedBytes = 0
+	})
+
+	markers, err := m.process(client, job, func(object storage.ObjectDetails) {
+		if _, found := copied[object.Name]; found {
+			m.done(job, object.Size)
+			return
+		}
+
+		m.copyObject(ctx, client, target, job, object)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// empty folders only exist through their markers
+	if _, err := client.Backend().Stat(ctx, job.Source); err == nil {
+		markers = append(markers, job.Source)
+	}
+
+	for _, marker := range markers {
+		destination := job.Destination + strings.TrimPrefix(marker, job.Source)
+		if destination == "" {
+			continue
+		}
+
+		if err := target.CreateFolder(ctx, destination); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyObject copies a single file of a TypeCopyFolder job and records the
+// outcome on the job.
+func (m *Manager) copyObject(ctx context.Context, client *storage.Client, target *storage.Client, job *Job, object storage.ObjectDetails) {
+	// the listing has no ETag, which the copy is verified against
+	info, err := client.Backend().Stat(ctx, object.Name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return // deleted in the meantime
+	}
+	if err != nil {
+		m.fail(job, object.Name, err)
+		return
+	}
+
+	destination := job.Destination + strings.TrimPrefix(object.Name, job.Source)
+
+	options := storage.CopyOptions{}
+	if job.Options != nil {
+		options = *job.Options
+	}
+
+	if _, err := client.CopyObject(ctx, *info, target, destination, job.Conflict, options, m.cache); err != nil {
+		// a cancelled job is resumed later, that is no failure
+		if ctx.Err() == nil {
+			m.fail(job, object.Name, err)
+		}
+		return
+	}
+
+	// without the record a resumed job would copy the object again
+	if err := m.store.Record(job.ID, object.Name, destination); err != nil {
+		m.fail(job, object.Name, fmt.Errorf("copied to %s but failed to record it: %w", destination, err))
+		return
+	}
+
+	m.done(job, info.Size)
+}
@@ -0,0 +1,414 @@
+// Package jobs runs long bucket operations, like moving a whole folder, in
+// the background. Jobs are persisted, report their progress while they run,
+// and are resumed after a restart.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"file-management-service/config"
+	"file-management-service/pkg/cache"
+	"file-management-service/pkg/storage"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrJobRunning is returned when resuming a job that is still running.
+var ErrJobRunning = errors.New("job is still running")
+
+// ErrJobCompleted is returned when resuming a job that has completed.
+var ErrJobCompleted = errors.New("job has already completed")
+
+// State is the lifecycle state of a job.
+type State string
+
+const (
+	StatePending   State = "pending"
+	StateRunning   State = "running"
+	StateCompleted State = "completed"
+	StateFailed    State = "failed"
+)
+
+// maxFailures bounds the failures kept on a job; the count is always exact.
+const maxFailures = 100
+
+// saveInterval is how often the progress of a running job is persisted.
+const saveInterval = 2 * time.Second
+
+// Job is the persisted state of one background operation.
+type Job struct {
+	ID          string                 `json:"id"`
+	Type        string                 `json:"type"`
+	Bucket      string                 `json:"bucket"`
+	Source      string                 `json:"source"`
+	Destination string                 `json:"destination"`
+	Conflict    storage.ConflictPolicy `json:"conflict"`
+	State       State                  `json:"state"`
+
+	// TargetBucket and Options are only set for copies; an empty target
+	// bucket is Bucket itself.
+	TargetBucket string               `json:"targetBucket,omitempty"`
+	Options      *storage.CopyOptions `json:"options,omitempty"`
+
+	// TotalObjects and TotalBytes are what was processed plus what is left
+	// to process, counted whenever the job (re)starts
+	TotalObjects     int64 `json:"totalObjects"`
+	TotalBytes       int64 `json:"totalBytes"`
+	ProcessedObjects int64 `json:"processedObjects"`
+	ProcessedBytes   int64 `json:"processedBytes"`
+	FailedObjects    int64 `json:"failedObjects"`
+
+	// Runs counts the times the job was started, resumes included
+	Runs int `json:"runs"`
+
+	Failures []Failure `json:"failures,omitempty"`
+	Error    string    `json:"error,omitempty"`
+
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Failure is an object a job could not process.
+type Failure struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// Manager runs jobs against the buckets of a Registry.
+type Manager struct {
+	ctx         context.Context
+	store       *Store
+	buckets     *storage.Registry
+	cache       *cache.URLCache
+	concurrency int
+
+	// running holds the live state of running jobs, guarded by mutex
+	mutex   sync.Mutex
+	running map[string]*Job
+	wg      sync.WaitGroup
+}
+
+// NewManager creates a Manager keeping its jobs in config.JobStateDir.
+func NewManager(config *config.Config, buckets *storage.Registry, cache *cache.URLCache) (*Manager, error) {
+	store, err := NewStore(config.JobStateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manager{
+		ctx:         context.Background(),
+		store:       store,
+		buckets:     buckets,
+		cache:       cache,
+		concurrency: config.JobConcurrency,
+		running:     make(map[string]*Job),
+	}, nil
+}
+
+// Start resumes the jobs that were pending or running when the service
+// stopped. Jobs stop when ctx is cancelled, and are resumed on the next
+// start.
+func (m *Manager) Start(ctx context.Context) {
+	m.ctx = ctx
+
+	jobs, err := m.store.List()
+	if err != nil {
+		log.Printf("Failed to load jobs: %s", err)
+		return
+	}
+
+	for _, job := range jobs {
+		if job.State == StatePending || job.State == StateRunning {
+			log.Printf("Resuming %s job %s", job.Type, job.ID)
+			m.launch(job)
+		}
+	}
+}
+
+// Close waits for the running jobs to save their state. Cancel the context
+// passed to Start first.
+func (m *Manager) Close() {
+	m.wg.Wait()
+}
+
+// Get returns a snapshot of a job.
+func (m *Manager) Get(id string) (*Job, error) {
+	m.mutex.Lock()
+	if job, found := m.running[id]; found {
+		snapshot := job.snapshot()
+		m.mutex.Unlock()
+		return snapshot, nil
+	}
+	m.mutex.Unlock()
+
+	return m.store.Get(id)
+}
+
+// Resume restarts a failed job. Only the objects that are left are processed.
+func (m *Manager) Resume(id string) (*Job, error) {
+	// checked and claimed under the lock, so a job is never resumed twice
+	m.mutex.Lock()
+	job, err := m.claim(id)
+	m.mutex.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.launch(job), nil
+}
+
+// claim marks a stopped job as pending again. The caller holds the lock.
+func (m *Manager) claim(id string) (*Job, error) {
+	if _, running := m.running[id]; running {
+		return nil, ErrJobRunning
+	}
+
+	job, err := m.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.State == StateCompleted {
+		return nil, ErrJobCompleted
+	}
+
+	job.State = StatePending
+	job.Error = ""
+	job.UpdatedAt = time.Now().UTC()
+	if err := m.store.Save(job); err != nil {
+		return nil, err
+	}
+
+	m.running[id] = job
+	return job, nil
+}
+
+// create persists a new job and starts it.
+func (m *Manager) create(job *Job) (*Job, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	job.ID = hex.EncodeToString(id)
+	job.State = StatePending
+	job.CreatedAt = time.Now().UTC()
+	job.UpdatedAt = job.CreatedAt
+
+	if err := m.store.Save(job); err != nil {
+		return nil, err
+	}
+
+	return m.launch(job), nil
+}
+
+// launch runs a job in the background and returns a snapshot of it.
+func (m *Manager) launch(job *Job) *Job {
+	m.mutex.Lock()
+	m.running[job.ID] = job
+	snapshot := job.snapshot()
+	m.mutex.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		err := m.run(job)
+
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		// a job interrupted by shutdown stays running, so it is resumed on
+		// the next start
+		if m.ctx.Err() == nil {
+			now := time.Now().UTC()
+			job.FinishedAt = &now
+
+			switch {
+			case err != nil:
+				job.State = StateFailed
+				job.Error = err.Error()
+			case job.FailedObjects > 0:
+				job.State = StateFailed
+			default:
+				job.State = StateCompleted
+			}
+		}
+
+		// a completed job is never resumed, so its keys are not needed
+		if job.State == StateCompleted {
+			if err := m.store.Forget(job.ID); err != nil {
+				log.Printf("Failed to remove the keys of job %s: %s", job.ID, err)
+			}
+		}
+
+		job.UpdatedAt = time.Now().UTC()
+		if err := m.store.Save(job); err != nil {
+			log.Printf("Failed to save job %s: %s", job.ID, err)
+		}
+
+		delete(m.running, job.ID)
+	}()
+
+	return snapshot
+}
+
+// run dispatches a job to the function implementing its type.
+func (m *Manager) run(job *Job) error {
+	client, err := m.buckets.Client(job.Bucket)
+	if err != nil {
+		return err
+	}
+
+	m.update(job, func() {
+		job.State = StateRunning
+		job.FinishedAt = nil
+		job.Runs++
+	})
+
+	// persist the progress while the job runs; the saver is stopped before
+	// the final state is saved, so it cannot overwrite it with a stale one
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(saveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.mutex.Lock()
+				snapshot := job.snapshot()
+				m.mutex.Unlock()
+
+				if err := m.store.Save(snapshot); err != nil {
+					log.Printf("Failed to save job %s: %s", job.ID, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	switch job.Type {
+	case TypeMoveFolder:
+		return m.moveFolder(client, job)
+	case TypeCopyFolder:
+		return m.copyFolder(client, job)
+	}
+
+	return errors.New("unknown job type " + job.Type)
+}
+
+// update changes a running job while holding the lock.
+func (m *Manager) update(job *Job, change func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	change()
+	job.UpdatedAt = time.Now().UTC()
+}
+
+// process counts the files below the source of a job and calls fn for each
+// of them, m.concurrency at a time. The folder markers that were found are
+// returned in key order. fn reports the outcome with done or fail.
+func (m *Manager) process(client *storage.Client, job *Job, fn func(object storage.ObjectDetails)) ([]string, error) {
+	ctx := m.ctx
+
+	// count what is left to process
+	var objects, size int64
+	err := client.Walk(ctx, job.Source, storage.WalkOptions{}, func(object storage.ObjectDetails) error {
+		if !object.IsFolder {
+			objects++
+			size += object.Size
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// whatever was processed before plus what is left is the total, so the
+	// progress stays consistent when the source changed in between
+	m.update(job, func() {
+		job.TotalObjects = job.ProcessedObjects + objects
+		job.TotalBytes = job.ProcessedBytes + size
+		job.FailedObjects = 0
+		job.Failures = nil
+	})
+
+	work := make(chan storage.ObjectDetails)
+	var wg sync.WaitGroup
+
+	for i := 0; i < m.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for object := range work {
+				fn(object)
+			}
+		}()
+	}
+
+	var markers []string
+	err = client.Walk(ctx, job.Source, storage.WalkOptions{}, func(object storage.ObjectDetails) error {
+		if object.IsFolder {
+			markers = append(markers, object.Name)
+			return nil
+		}
+
+		select {
+		case work <- object:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	close(work)
+	wg.Wait()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return markers, ctx.Err()
+}
+
+// done records an object the job has processed.
+func (m *Manager) done(job *Job, size int64) {
+	m.update(job, func() {
+		job.ProcessedObjects++
+		job.ProcessedBytes += size
+	})
+}
+
+// fail records an object the job could not process.
+func (m *Manager) fail(job *Job, key string, err error) {
+	m.update(job, func() {
+		job.FailedObjects++
+		if len(job.Failures) < maxFailures {
+			job.Failures = append(job.Failures, Failure{Key: key, Error: err.Error()})
+		}
+	})
+}
+
+// snapshot copies a job so it can be read without holding the lock.
+func (job *Job) snapshot() *Job {
+	snapshot := *job
+	snapshot.Failures = append([]Failure(nil), job.Failures...)
+	return &snapshot
+}
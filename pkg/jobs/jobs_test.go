@@ -0,0 +1,262 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"file-management-service/config"
+	"file-management-service/pkg/cache"
+	"file-management-service/pkg/memory"
+	"file-management-service/pkg/storage"
+)
+
+// stallingBackend blocks every Put until its context is cancelled while
+// stall is set, like an upload cut off by a shutdown.
+type stallingBackend struct {
+	storage.Storage
+	stall bool
+}
+
+func (b *stallingBackend) Put(ctx context.Context, key string, body io.Reader) error {
+	if b.stall {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return b.Storage.Put(ctx, key, body)
+}
+
+// testBucket is an in-memory bucket the managers of a test share, so it
+// survives a simulated restart.
+type testBucket struct {
+	dir     string
+	backend *stallingBackend
+	buckets *storage.Registry
+}
+
+// newTestBucket creates a bucket holding contents, which maps keys to their
+// content.
+func newTestBucket(t *testing.T, contents map[string]string) *testBucket {
+	t.Helper()
+
+	backend, err := memory.NewClient(&config.Config{Name: "test", PublicURL: "http://localhost", URLSigningKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bucket := &testBucket{
+		dir:     t.TempDir(),
+		backend: &stallingBackend{Storage: backend},
+		buckets: storage.NewRegistry("test"),
+	}
+	bucket.buckets.Register(storage.NewClient("test", bucket.backend))
+
+	for key, content := range contents {
+		if err := backend.Put(context.Background(), key, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return bucket
+}
+
+// manager creates a Manager working on the bucket, as after a restart.
+func (b *testBucket) manager(t *testing.T) *Manager {
+	t.Helper()
+
+	m, err := NewManager(&config.Config{JobStateDir: b.dir, JobConcurrency: 2}, b.buckets, cache.NewURLCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// contents returns every key of the bucket with its content.
+func (b *testBucket) contents(t *testing.T) map[string]string {
+	t.Helper()
+
+	ctx := context.Background()
+	resp, err := b.backend.List(ctx, storage.ListInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := make(map[string]string)
+	for _, object := range resp.Objects {
+		body, err := b.backend.Get(ctx, object.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[object.Key] = string(data)
+	}
+	return contents
+}
+
+// interrupted saves a job as it is left behind by a shutdown, with the keys
+// its first run recorded.
+func interrupted(t *testing.T, m *Manager, job *Job, recorded map[string]string) {
+	t.Helper()
+
+	job.ID = strings.Repeat("a", 32)
+	job.Bucket = "test"
+	job.State = StateRunning
+	job.Runs = 1
+	job.CreatedAt = time.Now().UTC()
+
+	if err := m.store.Save(job); err != nil {
+		t.Fatal(err)
+	}
+	for key, destination := range recorded {
+		if err := m.store.Record(job.ID, key, destination); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// resume starts the manager and waits for the resumed jobs to stop.
+func resume(t *testing.T, m *Manager, id string) *Job {
+	t.Helper()
+
+	m.Start(context.Background())
+	m.Close()
+
+	job, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestResumeMove(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   storage.ConflictPolicy
+		contents map[string]string
+		recorded map[string]string
+		state    State
+		want     map[string]string
+	}{
+		{
+			// a.txt was there before the job and must not be taken for a copy
+			name:     "fail",
+			policy:   storage.ConflictFail,
+			contents: map[string]string{"src/a.txt": "a", "src/b.txt": "b", "dst/a.txt": "a", "dst/b.txt": "b"},
+			recorded: map[string]string{"src/b.txt": "dst/b.txt"},
+			state:    StateFailed,
+			want:     map[string]string{"src/a.txt": "a", "dst/a.txt": "a", "dst/b.txt": "b"},
+		},
+		{
+			name:     "rename",
+			policy:   storage.ConflictRename,
+			contents: map[string]string{"src/a.txt": "a", "dst/a.txt": "old", "dst/a (1).txt": "a"},
+			recorded: map[string]string{"src/a.txt": "dst/a (1).txt"},
+			state:    StateCompleted,
+			want:     map[string]string{"dst/a.txt": "old", "dst/a (1).txt": "a"},
+		},
+		{
+			// the first run was cut off while copying
+			name:     "overwrite",
+			policy:   storage.ConflictOverwrite,
+			contents: map[string]string{"src/a.txt": "a", "dst/a.txt": "partial"},
+			recorded: map[string]string{"src/a.txt": "dst/a.txt"},
+			state:    StateCompleted,
+			want:     map[string]string{"dst/a.txt": "a"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bucket := newTestBucket(t, test.contents)
+			m := bucket.manager(t)
+
+			job := &Job{Type: TypeMoveFolder, Source: "src/", Destination: "dst/", Conflict: test.policy}
+			interrupted(t, m, job, test.recorded)
+
+			job = resume(t, m, job.ID)
+			if job.State != test.state || job.Runs != 2 {
+				t.Errorf("state %s after %d runs, want %s after 2: %+v", job.State, job.Runs, test.state, job.Failures)
+			}
+
+			if got := bucket.contents(t); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("bucket holds %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResumeCopySkipsRecordedKeys(t *testing.T) {
+	bucket := newTestBucket(t, map[string]string{"src/a.txt": "a", "src/b.txt": "b", "dst/a.txt": "a"})
+	m := bucket.manager(t)
+
+	// copying a.txt again would rename it next to its first copy
+	job := &Job{Type: TypeCopyFolder, Source: "src/", Destination: "dst/", Conflict: storage.ConflictRename}
+	interrupted(t, m, job, map[string]string{"src/a.txt": "dst/a.txt"})
+
+	job = resume(t, m, job.ID)
+	if job.State != StateCompleted || job.ProcessedObjects != 2 {
+		t.Errorf("state %s with %d processed objects", job.State, job.ProcessedObjects)
+	}
+
+	want := map[string]string{"src/a.txt": "a", "src/b.txt": "b", "dst/a.txt": "a", "dst/b.txt": "b"}
+	if got := bucket.contents(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("bucket holds %q, want %q", got, want)
+	}
+
+	// the keys of a completed job are not kept
+	if recorded, err := m.store.Recorded(job.ID); err != nil || len(recorded) != 0 {
+		t.Errorf("recorded %q after completion: %v", recorded, err)
+	}
+}
+
+func TestShutdownAndStart(t *testing.T) {
+	bucket := newTestBucket(t, map[string]string{"src/a.txt": "a"})
+	bucket.backend.stall = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := bucket.manager(t)
+	m.Start(ctx)
+
+	job, err := m.MoveFolder(ctx, "test", "src", "dst", storage.ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the job to start, the copy then stalls until the shutdown
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if running, _ := m.Get(job.ID); running.State == StateRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the job did not start")
+		}
+	}
+
+	cancel()
+	m.Close()
+
+	stopped, err := m.store.Get(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopped.State != StateRunning || stopped.FinishedAt != nil || stopped.FailedObjects != 0 {
+		t.Fatalf("job after shutdown: %+v", stopped)
+	}
+
+	bucket.backend.stall = false
+	job = resume(t, bucket.manager(t), job.ID)
+
+	if job.State != StateCompleted || job.Runs != 2 || job.ProcessedObjects != 1 {
+		t.Errorf("resumed job: %+v", job)
+	}
+	if got := bucket.contents(t); !reflect.DeepEqual(got, map[string]string{"dst/a.txt": "a"}) {
+		t.Errorf("bucket holds %q", got)
+	}
+}
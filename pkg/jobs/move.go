@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"file-management-service/pkg/storage"
+)
+
+// TypeMoveFolder moves every object below a folder to another folder.
+const TypeMoveFolder = "move-folder"
+
+// MoveFolder starts a job moving the folder source with everything below it
+// to destination, which may be empty for the root of the bucket. policy is
+// applied to every object whose destination already exists; objects that
+// cannot be moved are left in place and reported as failures.
+func (m *Manager) MoveFolder(ctx context.Context, bucket string, source string, destination string, policy storage.ConflictPolicy) (*Job, error) {
+	client, err := m.buckets.Client(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	source, destination, err = folderPaths(source, destination)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(destination, source) {
+		return nil, fmt.Errorf("cannot move %s into itself", source)
+	}
+
+	if err := checkFolder(ctx, client, source); err != nil {
+		return nil, err
+	}
+
+	return m.create(&Job{
+		Type:        TypeMoveFolder,
+		Bucket:      client.Name(),
+		Source:      source,
+		Destination: destination,
+		Conflict:    policy,
+	})
+}
+
+// moveFolder runs a TypeMoveFolder job. Files are moved concurrently, each
+// one copied, verified and only then deleted, so an interrupted job can be
+// resumed by moving whatever is still below the source. The key every file
+// is moved to is recorded with the job before it is copied, so a resumed job
+// finishes the move to that key. Folder markers are moved last, and only
+// when every file was moved, so a failed job keeps its source folders.
+func (m *Manager) moveFolder(client *storage.Client, job *Job) error {
+	ctx := m.ctx
+
+	moved, err := m.store.Recorded(job.ID)
+	if err != nil {
+		return err
+	}
+
+	markers, err := m.process(client, job, func(object storage.ObjectDetails) {
+		m.moveObject(ctx, client, job, object, moved[object.Name])
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if job.FailedObjects > 0 {
+		return nil
+	}
+
+	// the folder itself is not part of the walk
+	if _, err := client.Backend().Stat(ctx, job.Source); err == nil {
+		markers = append(markers, job.Source)
+	}
+
+	// nested folders go before their parents, which matters for backends
+	// where folders are real directories
+	sort.Sort(sort.Reverse(sort.StringSlice(markers)))
+
+	for _, marker := range markers {
+		destination := job.Destination + strings.TrimPrefix(marker, job.Source)
+		if destination != "" {
+			if err := client.CreateFolder(ctx, destination); err != nil {
+				return err
+			}
+		}
+
+		if err := client.DeleteObject(ctx, marker); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// moveObject moves a single file of a TypeMoveFolder job and records the
+// outcome on the job. recorded is the key an earlier run chose for the file.
+func (m *Manager) moveObject(ctx context.Context, client *storage.Client, job *Job, object storage.ObjectDetails, recorded string) {
+	// the listing has no ETag, which the copy is verified against
+	info, err := client.Backend().Stat(ctx, object.Name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return // deleted in the meantime
+	}
+	if err != nil {
+		m.fail(job, object.Name, err)
+		return
+	}
+
+	destination := job.Destination + strings.TrimPrefix(object.Name, job.Source)
+	policy := job.Conflict
+
+	if recorded != "" {
+		// the earlier run may have copied the file there already, whatever
+		// is at the key is its copy
+		destination, policy = recorded, storage.ConflictOverwrite
+	} else {
+		destination, err = client.FreeName(ctx, destination, policy)
+		if err != nil {
+			if ctx.Err() == nil {
+				m.fail(job, object.Name, err)
+			}
+			return
+		}
+
+		if err := m.store.Record(job.ID, object.Name, destination); err != nil {
+			m.fail(job, object.Name, err)
+			return
+		}
+	}
+
+	if _, err := client.MoveObject(ctx, *info, destination, policy, m.cache); err != nil {
+		// a cancelled job is resumed later, that is no failure
+		if ctx.Err() == nil {
+			m.fail(job, object.Name, err)
+		}
+		return
+	}
+
+	m.done(job, info.Size)
+}
+
+// folderPaths normalizes the source and destination folders of a job. The
+// destination may be empty for the root of the bucket.
+func folderPaths(source string, destination string) (string, string, error) {
+	source = strings.TrimPrefix(source, "/")
+	if source == "" {
+		return "", "", errors.New("the root of the bucket cannot be the source")
+	}
+	if !strings.HasSuffix(source, "/") {
+		source += "/"
+	}
+
+	destination = strings.TrimPrefix(destination, "/")
+	if destination != "" && !strings.HasSuffix(destination, "/") {
+		destination += "/"
+	}
+
+	return source, destination, nil
+}
+
+// checkFolder fails with storage.ErrNotFound unless the folder exists, as a
+// marker or through the objects below it.
+func checkFolder(ctx context.Context, client *storage.Client, folder string) error {
+	resp, err := client.List(ctx, storage.ListInput{Prefix: folder, MaxKeys: 1})
+	if err != nil {
+		return err
+	}
+	if len(resp.Objects) == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
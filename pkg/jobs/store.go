@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrJobNotFound is returned for unknown job IDs.
+var ErrJobNotFound = errors.New("job not found")
+
+// validID matches the IDs generated by the manager; anything else is rejected
+// before it gets near the file system.
+var validID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Store persists jobs in a directory so they survive restarts.
+type Store struct {
+	dir string
+
+	// mutex serializes the appends to the key logs
+	mutex sync.Mutex
+}
+
+// NewStore creates a Store keeping its files in dir.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// Get loads a job.
+func (s *Store) Get(id string) (*Job, error) {
+	if !validID.MatchString(id) {
+		return nil, ErrJobNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Save atomically writes the state of a job.
+func (s *Store) Save(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path(job.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path(job.ID))
+}
+
+// List loads all persisted jobs.
+func (s *Store) List() ([]*Job, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*Job
+	for _, file := range files {
+		job, err := s.Get(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// Record appends key and the key it was written to to the keys a job has
+// processed. The keys are kept in a log next to the job, one JSON array per
+// line, so recording one does not rewrite the others.
+func (s *Store) Record(id string, key string, destination string) error {
+	line, err := json.Marshal([2]string{key, destination})
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	file, err := os.OpenFile(s.keysPath(id), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Recorded loads the keys recorded for a job, with the keys they were
+// written to. A line cut short by a crash is skipped, its object is
+// processed again.
+func (s *Store) Recorded(id string) (map[string]string, error) {
+	keys := make(map[string]string)
+
+	file, err := os.Open(s.keysPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record [2]string
+		if json.Unmarshal(scanner.Bytes(), &record) == nil {
+			keys[record[0]] = record[1]
+		}
+	}
+
+	return keys, scanner.Err()
+}
+
+// Forget removes the keys recorded for a job.
+func (s *Store) Forget(id string) error {
+	err := os.Remove(s.keysPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *Store) keysPath(id string) string {
+	return filepath.Join(s.dir, id+".keys")
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
@@ -0,0 +1,432 @@
+package local
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"file-management-service/config"
+	"file-management-service/pkg/storage"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// tempPrefix marks partially written files, which are hidden from listings.
+const tempPrefix = ".upload-"
+
+// Local stores objects as files below a root directory. Folder objects (keys
+// ending with a slash) are represented by directories.
+type Local struct {
+	*storage.LinkSigner
+	root string
+}
+
+// Local must satisfy the backend neutral storage interface.
+var _ storage.Storage = (*Local)(nil)
+var _ storage.LinkVerifier = (*Local)(nil)
+
+// NewClient creates a new Local instance rooted at config.LocalStorageRoot.
+func NewClient(config *config.Config) (*Local, error) {
+	root, err := filepath.Abs(config.LocalStorageRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	// downloads are served by the service itself
+	signer, err := storage.NewLinkSigner(config.Name, config.PublicURL, config.URLSigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Local{
+		LinkSigner: signer,
+		root:       root,
+	}, nil
+}
+
+// resolve maps an object key onto a path below the root directory.
+func (l *Local) resolve(key string) (string, error) {
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." || part == "." {
+			return "", fmt.Errorf("invalid object key %q", key)
+		}
+	}
+
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the contents of body to the file for key. The data is written to
+// a temporary file first so readers never see a partial object.
+func (l *Local) Put(ctx context.Context, key string, body io.Reader) error {
+	p, err := l.resolve(key)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(key, "/") {
+		return os.MkdirAll(p, 0o755)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), tempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+// Get opens the file for key.
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := l.open(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// GetRange opens the file for key and returns a reader over a byte range of it.
+func (l *Local) GetRange(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	f, err := l.open(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if length < 0 {
+		return f, nil
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}, nil
+}
+
+// open opens the regular file backing key. Directories only exist as folder
+// markers and cannot be read.
+func (l *Local) open(key string) (*os.File, error) {
+	p, err := l.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if info.IsDir() {
+		f.Close()
+		return nil, storage.ErrNotFound
+	}
+
+	return f, nil
+}
+
+// Stat returns the metadata of the file for key.
+func (l *Local) Stat(ctx context.Context, key string) (*storage.ObjectInfo, error) {
+	p, err := l.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a directory only matches the folder form of the key and vice versa
+	if info.IsDir() != strings.HasSuffix(key, "/") {
+		return nil, storage.ErrNotFound
+	}
+
+	obj := objectInfo(key, info)
+	return &obj, nil
+}
+
+// List lists one page of keys below prefix. Directories are read in key
+// order, so the walk starts at the continuation token and stops once the
+// page is full instead of reading the whole tree for every page.
+func (l *Local) List(ctx context.Context, input storage.ListInput) (*storage.ListOutput, error) {
+	startAfter, err := storage.StartAfter(input.ContinuationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	maxKeys := input.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = storage.DefaultMaxKeys
+	}
+
+	// only walk the directory the prefix points into
+	base := ""
+	if idx := strings.LastIndex(input.Prefix, "/"); idx >= 0 {
+		base = input.Prefix[:idx+1]
+	}
+
+	start, err := l.resolve(base)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &listPage{input: input, startAfter: startAfter, maxKeys: maxKeys}
+
+	// the directory itself is listed when the prefix is exactly its key
+	if base != "" && base == input.Prefix && base > startAfter {
+		info, err := os.Stat(start)
+		if err == nil && info.IsDir() {
+			page.add(base, info)
+		}
+	}
+
+	err = l.listDir(start, base, page)
+	if err != nil && err != errPageFull {
+		return nil, err
+	}
+
+	// one key more than fits the page was collected, so Paginate can tell
+	// that the listing goes on
+	return storage.Paginate(page.objects, input)
+}
+
+// errPageFull stops the walk of a listing once it found enough keys.
+var errPageFull = errors.New("page is full")
+
+// listPage collects the objects of one page of a listing.
+type listPage struct {
+	input      storage.ListInput
+	startAfter string
+	maxKeys    int
+
+	objects []storage.ObjectInfo
+	entries int
+	last    string
+}
+
+// add collects an object and returns errPageFull once the page and one entry
+// beyond it are collected. Objects rolled up into one common prefix count as
+// one entry, like with Paginate.
+func (p *listPage) add(key string, info fs.FileInfo) error {
+	entry := key
+	if p.input.Delimiter != "" {
+		if idx := strings.Index(key[len(p.input.Prefix):], p.input.Delimiter); idx >= 0 {
+			entry = key[:len(p.input.Prefix)+idx+len(p.input.Delimiter)]
+		}
+	}
+
+	if entry <= p.startAfter {
+		return nil
+	}
+
+	p.objects = append(p.objects, objectInfo(key, info))
+
+	if p.entries == 0 || entry != p.last {
+		p.entries++
+		p.last = entry
+	}
+
+	if p.entries > p.maxKeys {
+		return errPageFull
+	}
+
+	return nil
+}
+
+// listDir adds the keys below the directory dir, whose key is prefix, to
+// page in key order. Entries are sorted by their keys rather than their
+// names, a directory "a" has the key "a/" and goes after a file "a-b".
+func (l *Local) listDir(dir string, prefix string, page *listPage) error {
+	f, err := os.Open(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// unlike os.ReadDir this doesn't sort by name, the entries are sorted
+	// by key below
+	entries, err := f.ReadDir(-1)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	// entries outside of the prefix or before the token are dropped, the
+	// rest is taken from a heap in key order, so a page of a large
+	// directory doesn't sort all of it
+	remaining := &keyHeap{keys: make([]string, 0, len(entries)), entries: entries[:0]}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), tempPrefix) {
+			continue
+		}
+
+		key := prefix + entry.Name()
+		if entry.IsDir() {
+			key += "/"
+		}
+
+		if !strings.HasPrefix(key, page.input.Prefix) && !strings.HasPrefix(page.input.Prefix, key) {
+			continue
+		}
+
+		// everything below a directory sorts between its key and the next
+		// one, so a directory before the token is skipped as a whole unless
+		// the token points into it
+		if key <= page.startAfter && !(entry.IsDir() && strings.HasPrefix(page.startAfter, key)) {
+			continue
+		}
+
+		remaining.keys = append(remaining.keys, key)
+		remaining.entries = append(remaining.entries, entry)
+	}
+
+	heap.Init(remaining)
+
+	for remaining.Len() > 0 {
+		next := heap.Pop(remaining).(keyedEntry)
+		key, entry := next.key, next.entry
+
+		if strings.HasPrefix(key, page.input.Prefix) {
+			info, err := entry.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				continue // removed since the directory was read
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := page.add(key, info); err != nil {
+				return err
+			}
+
+			// with a "/" delimiter everything below a child folder is rolled
+			// up into that folder's common prefix, so there is no need to
+			// descend
+			if entry.IsDir() && page.input.Delimiter == "/" {
+				continue
+			}
+		}
+
+		if entry.IsDir() {
+			if err := l.listDir(filepath.Join(dir, entry.Name()), key, page); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// keyedEntry is a directory entry with its key.
+type keyedEntry struct {
+	key   string
+	entry fs.DirEntry
+}
+
+// keyHeap is a min-heap of directory entries ordered by their keys.
+type keyHeap struct {
+	keys    []string
+	entries []fs.DirEntry
+}
+
+func (h *keyHeap) Len() int           { return len(h.keys) }
+func (h *keyHeap) Less(i, j int) bool { return h.keys[i] < h.keys[j] }
+func (h *keyHeap) Swap(i, j int) {
+	h.keys[i], h.keys[j] = h.keys[j], h.keys[i]
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+}
+
+func (h *keyHeap) Push(x any) {
+	next := x.(keyedEntry)
+	h.keys = append(h.keys, next.key)
+	h.entries = append(h.entries, next.entry)
+}
+
+func (h *keyHeap) Pop() any {
+	last := len(h.keys) - 1
+	next := keyedEntry{h.keys[last], h.entries[last]}
+	h.keys = h.keys[:last]
+	h.entries = h.entries[:last]
+	return next
+}
+
+// Delete removes the file for key. Deleting a folder only removes the
+// directory if it is empty, mirroring how deleting an S3 folder marker leaves
+// the objects below it untouched.
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.resolve(key)
+	if err != nil {
+		return err
+	}
+
+	if p == l.root {
+		return nil
+	}
+
+	err = os.Remove(p)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTEMPTY) || errors.Is(err, syscall.EEXIST) {
+		return nil
+	}
+
+	return err
+}
+
+func objectInfo(key string, info fs.FileInfo) storage.ObjectInfo {
+	obj := storage.ObjectInfo{
+		Key:          key,
+		LastModified: info.ModTime().UTC(),
+	}
+
+	if !info.IsDir() {
+		obj.Size = info.Size()
+		obj.ContentType = mime.TypeByExtension(path.Ext(key))
+	}
+
+	// the file system has no content hash, so derive the ETag from the
+	// modification time and size like most static file servers do
+	obj.ETag = fmt.Sprintf("\"%x-%x\"", info.ModTime().UnixNano(), obj.Size)
+
+	return obj
+}
@@ -0,0 +1,124 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"file-management-service/config"
+	"file-management-service/pkg/storage"
+)
+
+// treeKeys is a tree whose names sort differently from their keys: the
+// directory "a" has the key "a/", which goes after "a-b" and "a.txt".
+var treeKeys = []string{
+	"a-b",
+	"a.txt",
+	"a/",
+	"a/b/",
+	"a/b/c.txt",
+	"a/b/d.txt",
+	"a/b-c.txt",
+	"a/e/",
+	"a0/",
+	"a0/x.txt",
+	"b.txt",
+	"docs/",
+	"docs/2024/",
+	"docs/2024/q1.pdf",
+	"docs/2024-notes.txt",
+	"docs/a.txt",
+	"docs/ab/",
+	"docs/ab/c.txt",
+}
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+
+	l, err := NewClient(&config.Config{
+		Name:             "test",
+		PublicURL:        "http://localhost",
+		URLSigningKey:    "secret",
+		LocalStorageRoot: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range treeKeys {
+		if err := l.Put(context.Background(), key, strings.NewReader(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return l
+}
+
+// listAll pages through a listing and returns the keys and common prefixes
+// of every page.
+func listAll(t *testing.T, list func(storage.ListInput) (*storage.ListOutput, error), input storage.ListInput) [][]string {
+	t.Helper()
+
+	pages := [][]string{}
+	for {
+		resp, err := list(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		page := append([]string{}, resp.CommonPrefixes...)
+		for _, object := range resp.Objects {
+			page = append(page, object.Key)
+		}
+		sort.Strings(page)
+		pages = append(pages, page)
+
+		if !resp.IsTruncated {
+			return pages
+		}
+		input.ContinuationToken = resp.NextContinuationToken
+	}
+}
+
+func TestList(t *testing.T) {
+	l := newTestLocal(t)
+
+	// the whole tree, paginated in memory, is what every page must match
+	all := []storage.ObjectInfo{}
+	for _, key := range treeKeys {
+		all = append(all, storage.ObjectInfo{Key: key})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Key < all[j].Key })
+
+	paginate := func(input storage.ListInput) (*storage.ListOutput, error) {
+		return storage.Paginate(all, input)
+	}
+	list := func(input storage.ListInput) (*storage.ListOutput, error) {
+		return l.List(context.Background(), input)
+	}
+
+	for _, prefix := range []string{"", "a", "a/", "a/b", "docs/", "docs/2024", "docs/a", "missing/"} {
+		for _, delimiter := range []string{"", "/"} {
+			for _, maxKeys := range []int{1, 2, 3, 100} {
+				input := storage.ListInput{Prefix: prefix, Delimiter: delimiter, MaxKeys: maxKeys}
+
+				got := listAll(t, list, input)
+				want := listAll(t, paginate, input)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("prefix %q, delimiter %q, %d keys per page: got %q, want %q", prefix, delimiter, maxKeys, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestListInvalidToken(t *testing.T) {
+	l := newTestLocal(t)
+
+	if _, err := l.List(context.Background(), storage.ListInput{ContinuationToken: "!"}); !errors.Is(err, storage.ErrInvalidToken) {
+		t.Errorf("err = %v, want ErrInvalidToken", err)
+	}
+}
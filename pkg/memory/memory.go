@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"file-management-service/config"
+	"file-management-service/pkg/storage"
+	"io"
+	"mime"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory keeps all objects in process memory. It is meant for tests and
+// short lived preview environments; nothing survives a restart.
+type Memory struct {
+	*storage.LinkSigner
+	mutex   sync.RWMutex
+	objects map[string]object
+	keys    []string // sorted, kept in sync with objects
+}
+
+type object struct {
+	data []byte
+	info storage.ObjectInfo
+}
+
+// Memory must satisfy the backend neutral storage interface.
+var _ storage.Storage = (*Memory)(nil)
+var _ storage.LinkVerifier = (*Memory)(nil)
+
+// NewClient creates a new, empty Memory instance. Download links are served by
+// the service itself.
+func NewClient(config *config.Config) (*Memory, error) {
+	signer, err := storage.NewLinkSigner(config.Name, config.PublicURL, config.URLSigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Memory{
+		LinkSigner: signer,
+		objects:    make(map[string]object),
+	}, nil
+}
+
+// Put stores the contents of body under key.
+func (m *Memory) Put(ctx context.Context, key string, body io.Reader) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	// single part uploads to S3 use the MD5 of the content as ETag
+	sum := md5.Sum(data)
+
+	obj := object{
+		data: data,
+		info: storage.ObjectInfo{
+			Key:          key,
+			Size:         int64(len(data)),
+			LastModified: time.Now().UTC(),
+			ETag:         "\"" + hex.EncodeToString(sum[:]) + "\"",
+			ContentType:  mime.TypeByExtension(path.Ext(key)),
+		},
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, found := m.objects[key]; !found {
+		i := sort.SearchStrings(m.keys, key)
+		m.keys = append(m.keys, "")
+		copy(m.keys[i+1:], m.keys[i:])
+		m.keys[i] = key
+	}
+
+	m.objects[key] = obj
+
+	return nil
+}
+
+// Get returns a reader over the object stored under key.
+func (m *Memory) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mutex.RLock()
+	obj, found := m.objects[key]
+	m.mutex.RUnlock()
+
+	if !found {
+		return nil, storage.ErrNotFound
+	}
+
+	// stored slices are never modified in place, so no copy is needed
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+// GetRange returns a reader over a byte range of the object stored under key.
+func (m *Memory) GetRange(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	m.mutex.RLock()
+	obj, found := m.objects[key]
+	m.mutex.RUnlock()
+
+	if !found {
+		return nil, storage.ErrNotFound
+	}
+
+	data := obj.data
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	data = data[offset:]
+	if length >= 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Stat returns the metadata of the object stored under key.
+func (m *Memory) Stat(ctx context.Context, key string) (*storage.ObjectInfo, error) {
+	m.mutex.RLock()
+	obj, found := m.objects[key]
+	m.mutex.RUnlock()
+
+	if !found {
+		return nil, storage.ErrNotFound
+	}
+
+	info := obj.info
+	return &info, nil
+}
+
+// List lists one page of objects with ListObjectsV2 semantics.
+func (m *Memory) List(ctx context.Context, input storage.ListInput) (*storage.ListOutput, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	// only copy the range of keys that can match the prefix
+	start := sort.SearchStrings(m.keys, input.Prefix)
+	objects := []storage.ObjectInfo{}
+	for _, key := range m.keys[start:] {
+		if !strings.HasPrefix(key, input.Prefix) {
+			break
+		}
+		objects = append(objects, m.objects[key].info)
+	}
+
+	return storage.Paginate(objects, input)
+}
+
+// Delete removes the object stored under key. Deleting a missing key is not
+// an error, just like in S3.
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, found := m.objects[key]; !found {
+		return nil
+	}
+
+	delete(m.objects, key)
+
+	i := sort.SearchStrings(m.keys, key)
+	m.keys = append(m.keys[:i], m.keys[i+1:]...)
+
+	return nil
+}
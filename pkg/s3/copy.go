@@ -0,0 +1,341 @@
+package s3
+
+import (
+	"context"
+	"file-management-service/pkg/storage"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// S3 copies objects server side, also from other buckets.
+var _ storage.Copier = (*S3)(nil)
+var _ storage.RemoteCopier = (*S3)(nil)
+var _ storage.ObjectWriter = (*S3)(nil)
+
+// maxCopyObjectSize is the largest object a single CopyObject request can
+// copy; larger objects are copied part by part.
+const maxCopyObjectSize = 5 * 1024 * 1024 * 1024
+
+// copyPartSize is the minimum part size of multipart copies. It is raised
+// for objects that would otherwise need more than maxParts parts.
+const copyPartSize = 512 * 1024 * 1024
+
+// maxParts is the largest number of parts of a multipart upload.
+const maxParts = 10000
+
+// Copy copies source to destination within the bucket without downloading
+// it. Content type, metadata, tags, storage class and encryption are kept,
+// except for what options replace. The copy is pinned to the source's
+// current ETag, so a source replaced halfway through fails the copy instead
+// of producing a mix of both.
+func (s *S3) Copy(ctx context.Context, source string, destination string, options storage.CopyOptions) error {
+	return s.copyFrom(ctx, s, source, destination, options)
+}
+
+// CanCopyFrom reports whether objects of source can be copied server side,
+// which takes an S3 bucket on the same endpoint that is accessed with the
+// same credentials.
+func (s *S3) CanCopyFrom(source storage.Storage) bool {
+	other, ok := source.(*S3)
+	if !ok || other.endpoint != s.endpoint {
+		return false
+	}
+
+	creds, err := s.svc.Config.Credentials.Get()
+	if err != nil {
+		return false
+	}
+
+	otherCreds, err := other.svc.Config.Credentials.Get()
+	if err != nil {
+		return false
+	}
+
+	return creds.AccessKeyID == otherCreds.AccessKeyID
+}
+
+// CopyFrom copies key of another bucket to destination without downloading
+// it, like Copy. The copy is encrypted with the default of this bucket, the
+// encryption keys of the source bucket may not apply here.
+func (s *S3) CopyFrom(ctx context.Context, source storage.Storage, key string, destination string, options storage.CopyOptions) error {
+	other, ok := source.(*S3)
+	if !ok {
+		return fmt.Errorf("cannot copy from %T server side", source)
+	}
+
+	return s.copyFrom(ctx, other, key, destination, options)
+}
+
+// PutObject uploads body like Put, with the content type, cache headers,
+// user metadata, tags and storage class of info.
+func (s *S3) PutObject(ctx context.Context, key string, body io.Reader, info storage.ObjectInfo) error {
+	input := &s3manager.UploadInput{
+		Bucket:   aws.String(s.bucketName),
+		Key:      aws.String(key),
+		Body:     body,
+		Metadata: aws.StringMap(info.Metadata),
+		Tagging:  tagging(info.Tags),
+	}
+
+	// objects of other backends have no storage class
+	if info.StorageClass != "" {
+		input.StorageClass = aws.String(info.StorageClass)
+	}
+
+	setHeaders(info, objectHeaders{
+		&input.ContentType,
+		&input.CacheControl,
+		&input.ContentEncoding,
+		&input.ContentDisposition,
+		&input.ContentLanguage,
+	})
+
+	if expires, err := http.ParseTime(info.Expires); err == nil {
+		input.Expires = aws.Time(expires)
+	}
+
+	_, err := s.uploader.UploadWithContext(ctx, input)
+	return err
+}
+
+// copyFrom copies key of the bucket of source, which may be s, to
+// destination.
+func (s *S3) copyFrom(ctx context.Context, source *S3, key string, destination string, options storage.CopyOptions) error {
+	info, err := source.Stat(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if info.Size > maxCopyObjectSize {
+		return s.copyMultipart(ctx, source, info, destination, options)
+	}
+
+	copied := options.Apply(*info)
+
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(s.bucketName),
+		Key:               aws.String(destination),
+		CopySource:        aws.String(source.copySource(key)),
+		CopySourceIfMatch: aws.String(info.ETag),
+		StorageClass:      aws.String(copied.StorageClass),
+	}
+
+	// replacing the metadata replaces all headers, so the cache headers of
+	// the source are sent again
+	if options.ReplaceMetadata {
+		input.MetadataDirective = aws.String(s3.MetadataDirectiveReplace)
+		input.Metadata = aws.StringMap(copied.Metadata)
+
+		setHeaders(copied, objectHeaders{
+			&input.ContentType,
+			&input.CacheControl,
+			&input.ContentEncoding,
+			&input.ContentDisposition,
+			&input.ContentLanguage,
+		})
+
+		if expires, err := http.ParseTime(copied.Expires); err == nil {
+			input.Expires = aws.Time(expires)
+		}
+	}
+
+	// without these S3 would encrypt the copy with the bucket default
+	if source == s {
+		if info.ServerSideEncryption != "" {
+			input.ServerSideEncryption = aws.String(info.ServerSideEncryption)
+		}
+		if info.SSEKMSKeyID != "" {
+			input.SSEKMSKeyId = aws.String(info.SSEKMSKeyID)
+		}
+	}
+
+	_, err = s.svc.CopyObjectWithContext(ctx, input)
+	return translateError(err)
+}
+
+// copyMultipart copies an object larger than maxCopyObjectSize with
+// UploadPartCopy. Unlike CopyObject this copies nothing but the data, so the
+// metadata and tags of the source are set on the new upload explicitly.
+func (s *S3) copyMultipart(ctx context.Context, source *S3, info *storage.ObjectInfo, destination string, options storage.CopyOptions) error {
+	tags, err := source.GetTags(ctx, info.Key)
+	if err != nil {
+		return err
+	}
+
+	copied := options.Apply(*info)
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:       aws.String(s.bucketName),
+		Key:          aws.String(destination),
+		Metadata:     aws.StringMap(copied.Metadata),
+		StorageClass: aws.String(copied.StorageClass),
+		Tagging:      tagging(tags),
+	}
+
+	setHeaders(copied, objectHeaders{
+		&input.ContentType,
+		&input.CacheControl,
+		&input.ContentEncoding,
+		&input.ContentDisposition,
+		&input.ContentLanguage,
+	})
+
+	if source == s {
+		if info.ServerSideEncryption != "" {
+			input.ServerSideEncryption = aws.String(info.ServerSideEncryption)
+		}
+		if info.SSEKMSKeyID != "" {
+			input.SSEKMSKeyId = aws.String(info.SSEKMSKeyID)
+		}
+	}
+
+	if expires, err := http.ParseTime(copied.Expires); err == nil {
+		input.Expires = aws.Time(expires)
+	}
+
+	created, err := s.svc.CreateMultipartUploadWithContext(ctx, input)
+	if err != nil {
+		return err
+	}
+	uploadID := aws.StringValue(created.UploadId)
+
+	parts, err := s.copyParts(ctx, source, info, destination, uploadID)
+	if err != nil {
+		// don't leave the copied parts behind, they are billed until aborted
+		s.AbortMultipartUpload(context.Background(), destination, uploadID)
+		return err
+	}
+
+	return s.CompleteMultipartUpload(ctx, destination, uploadID, parts)
+}
+
+// copyParts copies the parts of a multipart copy, copyConcurrency at a time.
+func (s *S3) copyParts(ctx context.Context, source *S3, info *storage.ObjectInfo, destination string, uploadID string) ([]storage.CompletedPart, error) {
+	partSize := int64(copyPartSize)
+	if minimum := (info.Size + maxParts - 1) / maxParts; minimum > partSize {
+		partSize = minimum
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mutex    sync.Mutex
+		parts    []storage.CompletedPart
+		firstErr error
+		wg       sync.WaitGroup
+	)
+
+	slots := make(chan struct{}, s.copyConcurrency)
+
+	for number, offset := 1, int64(0); offset < info.Size; number, offset = number+1, offset+partSize {
+		end := offset + partSize
+		if end > info.Size {
+			end = info.Size
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(number int, offset int64, end int64) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result, err := s.svc.UploadPartCopyWithContext(ctx, &s3.UploadPartCopyInput{
+				Bucket:            aws.String(s.bucketName),
+				Key:               aws.String(destination),
+				UploadId:          aws.String(uploadID),
+				PartNumber:        aws.Int64(int64(number)),
+				CopySource:        aws.String(source.copySource(info.Key)),
+				CopySourceIfMatch: aws.String(info.ETag),
+				CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", offset, end-1)),
+			})
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = translateError(err)
+				}
+				cancel()
+				return
+			}
+
+			parts = append(parts, storage.CompletedPart{
+				Number: number,
+				ETag:   aws.StringValue(result.CopyPartResult.ETag),
+				Size:   end - offset,
+			})
+		}(number, offset, end)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Number < parts[j].Number
+	})
+
+	return parts, nil
+}
+
+// copySource returns the URL encoded x-amz-copy-source of a key.
+func (s *S3) copySource(key string) string {
+	return (&url.URL{Path: s.bucketName + "/" + key}).EscapedPath()
+}
+
+// objectHeaders points at the header fields of an S3 request input: content
+// type, cache control, content encoding, disposition and language.
+type objectHeaders [5]**string
+
+// setHeaders sets the headers of info that are not empty.
+func setHeaders(info storage.ObjectInfo, fields objectHeaders) {
+	values := [5]string{
+		info.ContentType,
+		info.CacheControl,
+		info.ContentEncoding,
+		info.ContentDisposition,
+		info.ContentLanguage,
+	}
+
+	for i, value := range values {
+		if value != "" {
+			*fields[i] = aws.String(value)
+		}
+	}
+}
+
+// tagging encodes tags as the x-amz-tagging header, nil without tags.
+func tagging(tags map[string]string) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	values := url.Values{}
+	for key, value := range tags {
+		values.Set(key, value)
+	}
+
+	return aws.String(values.Encode())
+}
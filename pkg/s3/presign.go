@@ -0,0 +1,153 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"file-management-service/pkg/storage"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3 lets browsers upload directly to the bucket.
+var _ storage.UploadPresigner = (*S3)(nil)
+
+// PresignUpload returns a presigned PUT request and an equivalent browser POST
+// policy for key. The content type is enforced by both; the maximum size can
+// only be enforced by the POST policy, while PUT can pin an exact size. With
+// a maximum size but no exact one, only the POST policy is returned, as the
+// PUT request would take an object of any size.
+func (s *S3) PresignUpload(key string, input storage.PresignUploadInput) (*storage.PresignedUpload, error) {
+	post, err := s.presignPost(key, input)
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &storage.PresignedUpload{
+		Key:     key,
+		Post:    post,
+		Expires: time.Now().Add(input.Expiry).UTC(),
+	}
+
+	if input.MaxSize > 0 && input.Size <= 0 {
+		return upload, nil
+	}
+
+	putInput := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	}
+
+	if input.ContentType != "" {
+		putInput.ContentType = aws.String(input.ContentType)
+	}
+
+	if input.Size > 0 {
+		putInput.ContentLength = aws.Int64(input.Size)
+	}
+
+	req, _ := s.svc.PutObjectRequest(putInput)
+	url, signedHeaders, err := req.PresignRequest(input.Expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	// the client has to send the signed headers along with the request
+	headers := make(map[string]string)
+	for name, values := range signedHeaders {
+		if !strings.EqualFold(name, "Host") && len(values) > 0 {
+			headers[name] = values[0]
+		}
+	}
+
+	upload.URL = url
+	upload.Method = http.MethodPut
+	upload.Headers = headers
+
+	return upload, nil
+}
+
+// presignPost builds a SigV4 signed POST policy, which the SDK does not
+// provide. See https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html
+func (s *S3) presignPost(key string, input storage.PresignUploadInput) (*storage.PresignedPost, error) {
+	creds, err := s.svc.Config.Credentials.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	// resolve the bucket URL the same way the SDK does for any other request,
+	// which honours custom endpoints and path style addressing
+	bucketReq, _ := s.svc.HeadBucketRequest(&s3.HeadBucketInput{Bucket: aws.String(s.bucketName)})
+	if err := bucketReq.Build(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	region := aws.StringValue(s.svc.Config.Region)
+	date := now.Format("20060102")
+	credential := creds.AccessKeyID + "/" + date + "/" + region + "/s3/aws4_request"
+
+	fields := map[string]string{
+		"key":              key,
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": credential,
+		"x-amz-date":       now.Format("20060102T150405Z"),
+	}
+
+	if creds.SessionToken != "" {
+		fields["x-amz-security-token"] = creds.SessionToken
+	}
+
+	if input.ContentType != "" {
+		fields["Content-Type"] = input.ContentType
+	}
+
+	conditions := []interface{}{
+		map[string]string{"bucket": s.bucketName},
+	}
+	for name, value := range fields {
+		conditions = append(conditions, map[string]string{name: value})
+	}
+
+	if input.MaxSize > 0 {
+		conditions = append(conditions, []interface{}{"content-length-range", 0, input.MaxSize})
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"expiration": now.Add(input.Expiry).Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	encodedPolicy := base64.StdEncoding.EncodeToString(policy)
+
+	signingKey := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	fields["policy"] = encodedPolicy
+	fields["x-amz-signature"] = hex.EncodeToString(hmacSHA256(signingKey, encodedPolicy))
+
+	url := *bucketReq.HTTPRequest.URL
+	url.RawQuery = ""
+
+	return &storage.PresignedPost{
+		URL:    url.String(),
+		Fields: fields,
+	}, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
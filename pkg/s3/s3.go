@@ -1,461 +1,444 @@
 package s3
 
 import (
+	"bytes"
+	"context"
 	"file-management-service/config"
-	"file-management-service/pkg/cache"
+	"file-management-service/pkg/storage"
+	"fmt"
 	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 // S3 represents the Amazon S3 service.
 type S3 struct {
-	bucketName string
-	svc        *s3.S3
+	bucketName      string
+	endpoint        string
+	svc             *s3.S3
+	uploader        *s3manager.Uploader
+	copyConcurrency int
 }
 
+// S3 must satisfy the backend neutral storage interface.
+var _ storage.Storage = (*S3)(nil)
+var _ storage.MultipartUploader = (*S3)(nil)
+var _ storage.TagReader = (*S3)(nil)
+var _ storage.BatchDeleter = (*S3)(nil)
+
 // NewS3 creates a new S3 instance with the specified bucket name and AWS session.
+// The client is meant to be created once at startup and shared, the session
+// is safe for concurrent use. Setting config.S3Endpoint points the client at an S3 compatible server such
+// as MinIO, Ceph RGW or LocalStack instead of AWS.
 func NewClient(config *config.Config) (*S3, error) {
+	awsConfig := aws.Config{
+		Region:           aws.String(config.Region), // Replace with your desired AWS region,
+		S3ForcePathStyle: aws.Bool(config.S3ForcePathStyle),
+		DisableSSL:       aws.Bool(config.S3DisableSSL),
+	}
+
+	// Explicitly configured keys win, otherwise credentials are resolved by
+	// the default chain: environment, shared credentials/config profile
+	// (including credential_process), web identity and container or instance
+	// roles. The SDK refreshes temporary credentials before they expire.
+	if config.AwsAccessKeyID != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(
+			config.AwsAccessKeyID,
+			config.AwsSecretAccessKey,
+			config.AwsSessionToken,
+		)
+	}
+
+	if config.S3Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.S3Endpoint)
+	}
+
+	options := session.Options{
+		Config:            awsConfig,
+		Profile:           config.AwsProfile,
+		SharedConfigState: session.SharedConfigEnable,
+	}
+
+	// Trust a private CA in addition to the system roots, e.g. for a self
+	// hosted MinIO with its own certificate
+	if config.S3CABundle != "" {
+		bundle, err := os.Open(config.S3CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open S3 CA bundle: %w", err)
+		}
+		defer bundle.Close()
+
+		options.CustomCABundle = bundle
+	}
+
 	// Create a new AWS session
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(config.Region), // Replace with your desired AWS region,
-		Credentials: credentials.NewStaticCredentials(
-			config.AwsAccessKeyID,     // Replace with your AWS access key ID
-			config.AwsSecretAccessKey, // Replace with your AWS secret access key
-			"",
-		),
-	})
+	sess, err := session.NewSessionWithOptions(options)
 
 	if err != nil {
 		return nil, err
 	}
 
+	// Resolve credentials once up front so a misconfiguration shows up in
+	// the startup logs rather than on the first request
+	if creds, err := sess.Config.Credentials.Get(); err != nil {
+		log.Printf("Failed to resolve AWS credentials: %s", err)
+	} else {
+		log.Printf("Using AWS credentials from %s", creds.ProviderName)
+	}
+
 	// Create an S3 service client
 	svc := s3.New(sess)
 
+	// Uploads are streamed in parts, so memory use is bounded by part size
+	// times concurrency no matter how large the file is. A failed or
+	// cancelled upload aborts the multipart upload instead of leaving parts.
+	uploader := s3manager.NewUploaderWithClient(svc, func(u *s3manager.Uploader) {
+		u.PartSize = int64(config.UploadPartSizeMB) * 1024 * 1024
+		u.Concurrency = config.UploadConcurrency
+		u.LeavePartsOnError = false
+	})
+
 	return &S3{
-		bucketName: config.BucketName,
-		svc:        svc,
+		bucketName:      config.BucketName,
+		endpoint:        config.S3Endpoint,
+		svc:             svc,
+		uploader:        uploader,
+		copyConcurrency: config.UploadConcurrency,
 	}, nil
 }
 
-// CreateFolder creates a folder (empty object) in the specified bucket and folder path
-func (s *S3) CreateFolder(folderPath string) error {
-	// Add a trailing slash to the folder path if not already present
-	if folderPath != "" && !strings.HasSuffix(folderPath, "/") {
-		folderPath += "/"
-	}
-
-	// Create an empty object with the folder path as the key
-	input := &s3.PutObjectInput{
-		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(folderPath),
-	}
-
-	_, err := s.svc.PutObject(input)
+// Put uploads the contents of body to the S3 bucket under key. Bodies larger
+// than one part are sent as a multipart upload, which is aborted when ctx is
+// cancelled or reading body fails. The content type is taken from the
+// extension of key, or sniffed from the content, as S3 would store
+// binary/octet-stream otherwise.
+func (s *S3) Put(ctx context.Context, key string, body io.Reader) error {
+	contentType, body, err := detectContentType(key, body)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
-
-// UploadFile uploads a file to the S3 bucket.
-func (s *S3) UploadFile(src io.Reader, objectKey string) error {
-	// Upload the file to S3
-	_, err := s.svc.PutObject(&s3.PutObjectInput{
-		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(objectKey),
-		Body:   aws.ReadSeekCloser(src),
+	_, err = s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(s.bucketName),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
 	})
-	if err != nil {
-		return err
-	}
 
-	return nil
+	return err
 }
 
-// ListObjects lists all the objects within a folder in the S3 bucket.
-func (s *S3) ListFiles(folderPath string, nextPageToken string, pageSize int, isFolder bool, cache *cache.URLCache) (*ListFilesResponse, error) {
+// folderContentType is the content type of folder markers, which have no
+// content to sniff.
+const folderContentType = "application/x-directory"
 
-	// If the folder path does not end with a slash, add it
-	if (folderPath != "") && !strings.HasSuffix(folderPath, "/") {
-		folderPath += "/"
+// detectContentType returns the content type of an object about to be
+// uploaded, and a reader with the complete body.
+func detectContentType(key string, body io.Reader) (string, io.Reader, error) {
+	if strings.HasSuffix(key, "/") {
+		return folderContentType, body, nil
 	}
 
-	input := &s3.ListObjectsV2Input{
-		Bucket:    aws.String(s.bucketName),
-		Prefix:    aws.String(folderPath),
-		Delimiter: aws.String("/"),
-		MaxKeys:   aws.Int64(int64(pageSize + 1)),
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType, body, nil
 	}
 
-	if nextPageToken != "" {
-		input.ContinuationToken = aws.String(nextPageToken)
+	// http.DetectContentType looks at no more than the first 512 bytes
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
 	}
+	head = head[:n]
 
-	resp, err := s.svc.ListObjectsV2(input)
-
-	// send all file details
-	var objects []ObjectDetails
+	return http.DetectContentType(head), io.MultiReader(bytes.NewReader(head), body), nil
+}
 
-	for _, obj := range resp.CommonPrefixes {
-		objects = append(objects, ObjectDetails{
-			Name:         *obj.Prefix,
-			IsFolder:     true,
-			Size:         0,
-			LastModified: time.Now().UTC().Truncate(time.Second),
-		})
-	}
+// Get retrieves an object from the S3 bucket.
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	result, err := s.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
 
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
-	var fileCount int32 = 0
-
-	if !isFolder {
-		for _, obj := range resp.Contents {
-			if *obj.Key == folderPath {
-				continue // skip the folder itself
-			}
-
-			fileCount++
-			objects = append(objects, ObjectDetails{
-				Name:         *obj.Key,
-				IsFolder:     *obj.Size == 0,
-				Size:         *obj.Size,
-				LastModified: *obj.LastModified,
-			})
-
-			// generate a signed download URL for the object
-			downloadURL, err := s.GenerateDownloadLink(*obj.Key, cache)
-
-			if err != nil {
-				return nil, err
-			}
+	return result.Body, nil
+}
 
-			objects[len(objects)-1].DownloadLink = downloadURL
-		}
+// GetRange retrieves a byte range of an object from the S3 bucket.
+func (s *S3) GetRange(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		byteRange += strconv.FormatInt(offset+length-1, 10)
 	}
 
-	nextToken := ""
-	if resp.NextContinuationToken != nil {
-		nextToken = *resp.NextContinuationToken
-	}
+	result, err := s.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+		Range:  aws.String(byteRange),
+	})
 
-	response := &ListFilesResponse{
-		Files:               &objects,
-		NextPageToken:       nextToken,
-		IsLastPage:          !*resp.IsTruncated,
-		NoOfRecordsReturned: int32(len(objects)),
-		FilesCount:          fileCount,
-		FoldersCount:        int32(len(resp.CommonPrefixes)),
+	if err != nil {
+		return nil, translateError(err)
 	}
 
-	return response, nil
+	return result.Body, nil
 }
 
-func (s *S3) ListAllFiles(folderPath string) (*ListFilesResponse, error) {
-	objects, err := s.ListFiles(folderPath, "", 10, false, &cache.URLCache{})
-	nextToken := objects.NextPageToken
+// Stat returns the metadata of an object without downloading it.
+func (s *S3) Stat(ctx context.Context, key string) (*storage.ObjectInfo, error) {
+	result, err := s.svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
+
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
-	var allObjects []ObjectDetails
+	info := &storage.ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(result.ContentLength),
+		LastModified: aws.TimeValue(result.LastModified),
+		ETag:         aws.StringValue(result.ETag),
+		ContentType:  aws.StringValue(result.ContentType),
 
-	// check if next page token is present
-	for nextToken != "" {
-		temp, _ := s.ListFiles(folderPath, nextToken, 10, false, &cache.URLCache{})
-		allObjects = append(allObjects, *temp.Files...)
+		// S3 leaves out the storage class for STANDARD objects
+		StorageClass:         aws.StringValue(result.StorageClass),
+		ServerSideEncryption: aws.StringValue(result.ServerSideEncryption),
+		SSEKMSKeyID:          aws.StringValue(result.SSEKMSKeyId),
+		VersionID:            aws.StringValue(result.VersionId),
+		Metadata:             aws.StringValueMap(result.Metadata),
 
-		if temp.IsLastPage {
-			nextToken = ""
-		}
-		nextToken = temp.NextPageToken
+		CacheControl:       aws.StringValue(result.CacheControl),
+		ContentEncoding:    aws.StringValue(result.ContentEncoding),
+		ContentDisposition: aws.StringValue(result.ContentDisposition),
+		ContentLanguage:    aws.StringValue(result.ContentLanguage),
+		Expires:            aws.StringValue(result.Expires),
 	}
 
-	// Helper function to recursively fetch objects from subfolders
-	var listObjectsRecursively func(path string) error
-	listObjectsRecursively = func(path string) error {
-		objects, err := s.ListFiles(path, "", 10, false, &cache.URLCache{})
-		nextToken := objects.NextPageToken
-
-		// check if next page token is present
-		for nextToken != "" {
-			t, _ := s.ListFiles(path, nextToken, 10, false, &cache.URLCache{})
-			allObjects = append(allObjects, *t.Files...)
-
-			if t.IsLastPage {
-				nextToken = ""
-			}
-			nextToken = t.NextPageToken
-		}
-
-		if err != nil {
-			return err
-		}
-
-		// Add the objects from the current folder to the result
-		allObjects = append(allObjects, *objects.Files...)
-
-		// Recursively fetch objects from subfolders
+	if info.StorageClass == "" {
+		info.StorageClass = s3.StorageClassStandard
+	}
 
-		for _, subfolder := range *objects.Files {
-			if subfolder.IsFolder {
-				err := listObjectsRecursively(subfolder.Name)
-				if err != nil {
-					return err
-				}
-			}
-		}
+	return info, nil
+}
 
-		return nil
+// List lists one page of objects in the S3 bucket.
+func (s *S3) List(ctx context.Context, in storage.ListInput) (*storage.ListOutput, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucketName),
+		Prefix: aws.String(in.Prefix),
 	}
 
-	// Recursively fetch objects from subfolders
-	for _, folder := range *objects.Files {
-		if folder.IsFolder {
-			err := listObjectsRecursively(folder.Name)
-			if err != nil {
-				return nil, err
-			}
-		}
+	if in.Delimiter != "" {
+		input.Delimiter = aws.String(in.Delimiter)
 	}
 
-	// Combine the initial folder's objects with the recursively fetched objects
-	allObjects = append(*objects.Files, allObjects...)
-
-	return &ListFilesResponse{
-		Files:               &allObjects,
-		NextPageToken:       objects.NextPageToken,
-		IsLastPage:          objects.IsLastPage,
-		NoOfRecordsReturned: int32(len(allObjects)),
-		FilesCount:          objects.FilesCount,
-		FoldersCount:        objects.FoldersCount,
-	}, nil
-}
-
-// GetFile retrieves a file from the specified bucket and key in S3.
-func (s *S3) GetFile(bucket, key string) (io.Reader, error) {
-	input := &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
+	if in.MaxKeys > 0 {
+		input.MaxKeys = aws.Int64(int64(in.MaxKeys))
 	}
 
-	result, err := s.svc.GetObject(input)
+	if in.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(in.ContinuationToken)
+	}
 
+	resp, err := s.svc.ListObjectsV2WithContext(ctx, input)
 	if err != nil {
 		return nil, err
 	}
 
-	return result.Body, nil
-}
-
-// Function to generate a signed download URL for the object
-func (s *S3) GenerateDownloadLink(objectKey string, cache *cache.URLCache) (string, error) {
-	url, found := cache.Get(objectKey)
-
-	// Check if the URL is already in the cache and valid
-	if found {
-		return url, nil
+	out := &storage.ListOutput{
+		NextContinuationToken: aws.StringValue(resp.NextContinuationToken),
+		IsTruncated:           aws.BoolValue(resp.IsTruncated),
 	}
 
-	expiryTime := 15 * time.Minute
-
-	req, _ := s.svc.GetObjectRequest(&s3.GetObjectInput{
-		Bucket:              aws.String(s.bucketName),
-		Key:                 aws.String(objectKey),
-		ResponseContentType: aws.String("image/png"),
-	})
-
-	downloadURL, err := req.Presign(expiryTime) // Set the validity period of the signed URL
-	if err != nil {
-		return "", err
+	for _, prefix := range resp.CommonPrefixes {
+		out.CommonPrefixes = append(out.CommonPrefixes, aws.StringValue(prefix.Prefix))
 	}
 
-	// Cache the URL with its expiration time
-	cache.Set(objectKey, downloadURL, time.Now().Add(expiryTime))
+	for _, obj := range resp.Contents {
+		out.Objects = append(out.Objects, storage.ObjectInfo{
+			Key:          aws.StringValue(obj.Key),
+			Size:         aws.Int64Value(obj.Size),
+			LastModified: aws.TimeValue(obj.LastModified),
+			ETag:         aws.StringValue(obj.ETag),
+			StorageClass: aws.StringValue(obj.StorageClass),
+		})
+	}
 
-	return downloadURL, nil
+	return out, nil
 }
 
-// DeleteObject deletes an object from the S3 bucket.
-func (s *S3) DeleteObject(objectKey string) error {
-	_, err := s.svc.DeleteObject(&s3.DeleteObjectInput{
+// Delete deletes an object from the S3 bucket.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(objectKey),
+		Key:    aws.String(key),
 	})
-	if err != nil {
-		return err
-	}
 
-	return nil
+	return err
 }
 
-// DeleteFolder deletes a folder and its contents recursively from the S3 bucket.
-func (s *S3) DeleteFolder(folderPath string) error {
-
-	// add a trailing slash to the folder path if not already present
-	if folderPath != "" && !strings.HasSuffix(folderPath, "/") {
-		folderPath += "/"
+// DeleteBatch deletes up to storage.MaxDeleteBatch objects with a single
+// DeleteObjects request.
+func (s *S3) DeleteBatch(ctx context.Context, keys []string) ([]storage.DeleteError, error) {
+	objects := make([]*s3.ObjectIdentifier, len(keys))
+	for i, key := range keys {
+		objects[i] = &s3.ObjectIdentifier{Key: aws.String(key)}
 	}
 
-	allObjects := []ObjectDetails{}
-
-	resp, err := s.svc.ListObjectsV2(&s3.ListObjectsV2Input{
-		Bucket:  aws.String(s.bucketName),
-		Prefix:  aws.String(folderPath),
-		MaxKeys: aws.Int64(2),
+	// quiet mode only reports the keys that failed
+	result, err := s.svc.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(s.bucketName),
+		Delete: &s3.Delete{
+			Objects: objects,
+			Quiet:   aws.Bool(true),
+		},
 	})
 
-	for _, obj := range resp.Contents {
-
-		if *obj.Key == folderPath {
-			continue // skip the folder itself
-		}
-
-		allObjects = append(allObjects, ObjectDetails{
-			Name:         *obj.Key,
-			IsFolder:     *obj.Size == 0,
-			Size:         *obj.Size,
-			LastModified: *obj.LastModified,
-		})
-	}
-
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	nextToken := resp.NextContinuationToken
+	var failures []storage.DeleteError
+	for _, failure := range result.Errors {
+		failures = append(failures, storage.DeleteError{
+			Key:   aws.StringValue(failure.Key),
+			Error: aws.StringValue(failure.Code) + ": " + aws.StringValue(failure.Message),
+		})
+	}
 
-	for nextToken != nil {
+	return failures, nil
+}
 
-		curr, err := s.svc.ListObjectsV2(&s3.ListObjectsV2Input{
-			Bucket:            aws.String(s.bucketName),
-			Prefix:            aws.String(folderPath),
-			MaxKeys:           aws.Int64(1000),
-			ContinuationToken: nextToken,
-		})
+// Presign generates a signed download URL for the object
+func (s *S3) Presign(key string, expiry time.Duration) (string, error) {
+	req, _ := s.svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
 
-		if err != nil {
-			return err
-		}
+	return req.Presign(expiry)
+}
 
-		for _, obj := range curr.Contents {
+// GetTags returns the tags attached to an object.
+func (s *S3) GetTags(ctx context.Context, key string) (map[string]string, error) {
+	result, err := s.svc.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
 
-			if *obj.Key == folderPath {
-				continue // skip the folder itself
-			}
+	if err != nil {
+		return nil, translateError(err)
+	}
 
-			allObjects = append(allObjects, ObjectDetails{
-				Name:         *obj.Key,
-				IsFolder:     *obj.Size == 0,
-				Size:         *obj.Size,
-				LastModified: *obj.LastModified,
-			})
+	if len(result.TagSet) == 0 {
+		return nil, nil
+	}
 
-			// update the next token
-			nextToken = curr.NextContinuationToken
+	tags := make(map[string]string, len(result.TagSet))
+	for _, tag := range result.TagSet {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
 
-			if nextToken == nil {
-				break
-			}
-		}
+	return tags, nil
+}
 
+// CreateMultipartUpload starts a multipart upload for key and returns its ID.
+// The content type is taken from the extension of key, the content is not
+// known yet.
+func (s *S3) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
 	}
 
-	for _, obj := range allObjects {
-		err := s.DeleteObject(obj.Name)
-		if err != nil {
-			return err
-		}
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		input.ContentType = aws.String(contentType)
 	}
 
-	// delete the folder itself
-	err = s.DeleteObject(folderPath)
+	result, err := s.svc.CreateMultipartUploadWithContext(ctx, input)
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	return aws.StringValue(result.UploadId), nil
 }
 
-// ListAllFolders lists all the folders within a folder in the S3 bucket.
-func (s *S3) ListAllFolders(folderPath string) []ObjectDetails {
-	// add a trailing slash to the folder path if not already present
-	if folderPath != "" && !strings.HasSuffix(folderPath, "/") {
-		folderPath += "/"
-	}
-
-	allObjects := []ObjectDetails{}
-
-	resp, err := s.svc.ListObjectsV2(&s3.ListObjectsV2Input{
-		Bucket:  aws.String(s.bucketName),
-		Prefix:  aws.String(folderPath),
-		MaxKeys: aws.Int64(1000),
+// UploadPart uploads one part of a multipart upload and returns its ETag.
+func (s *S3) UploadPart(ctx context.Context, key string, uploadID string, number int, body io.ReadSeeker) (string, error) {
+	result, err := s.svc.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(s.bucketName),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(int64(number)),
+		Body:       body,
 	})
 
-	for _, obj := range resp.Contents {
+	if err != nil {
+		return "", err
+	}
 
-		if *obj.Key == folderPath {
-			continue // skip the folder itself
-		}
+	return aws.StringValue(result.ETag), nil
+}
 
-		if *obj.Size == 0 {
-			allObjects = append(allObjects, ObjectDetails{
-				Name:         *obj.Key,
-				IsFolder:     *obj.Size == 0,
-				Size:         *obj.Size,
-				LastModified: *obj.LastModified,
-			})
-		}
+// CompleteMultipartUpload assembles the uploaded parts into the final object.
+func (s *S3) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []storage.CompletedPart) error {
+	completed := make([]*s3.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, &s3.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int64(int64(part.Number)),
+		})
 	}
 
-	if err != nil {
-		return allObjects
-	}
+	_, err := s.svc.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucketName),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
 
-	nextToken := resp.NextContinuationToken
+	return err
+}
 
-	for nextToken != nil {
-		curr, _ := s.svc.ListObjectsV2(&s3.ListObjectsV2Input{
-			Bucket:            aws.String(s.bucketName),
-			Prefix:            aws.String(folderPath),
-			MaxKeys:           aws.Int64(1000),
-			ContinuationToken: nextToken,
-		})
+// AbortMultipartUpload discards a multipart upload and all of its parts.
+func (s *S3) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	_, err := s.svc.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
 
-		for _, obj := range curr.Contents {
-
-			if *obj.Key == folderPath {
-				continue // skip the folder itself
-			}
-
-			if *obj.Size == 0 {
-				allObjects = append(allObjects, ObjectDetails{
-					Name:         *obj.Key,
-					IsFolder:     *obj.Size == 0,
-					Size:         *obj.Size,
-					LastModified: *obj.LastModified,
-				})
-			}
-
-			// update the next token
-			nextToken = curr.NextContinuationToken
-			if nextToken == nil {
-				break
-			}
-		}
+	return err
+}
 
+// translateError maps the S3 "not found" error codes onto storage.ErrNotFound.
+func translateError(err error) error {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return storage.ErrNotFound
+		}
 	}
 
-	return allObjects
+	return err
 }
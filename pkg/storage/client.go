@@ -0,0 +1,521 @@
+package storage
+
+import (
+	"context"
+	"file-management-service/pkg/cache"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// Client implements the folder oriented operations used by the routes on top
+// of any Storage backend.
+type Client struct {
+	name      string
+	backend   Storage
+	hidden    string
+	observers []Observer
+}
+
+// NewClient creates a new Client for the named bucket backed by the given
+// storage backend.
+func NewClient(name string, backend Storage) *Client {
+	return &Client{
+		name:    name,
+		backend: backend,
+	}
+}
+
+// Name returns the name clients use to select this bucket.
+func (s *Client) Name() string {
+	return s.name
+}
+
+// Backend returns the underlying storage backend.
+func (s *Client) Backend() Storage {
+	return s.backend
+}
+
+// Hide leaves the keys below prefix out of the listings of this client, and
+// doesn't report changes to them to the observers. Listings of the prefix
+// itself still include them. Hide must be called before the client is used.
+func (s *Client) Hide(prefix string) {
+	s.hidden = prefix
+}
+
+// List returns one page of the backend's listing, without the hidden keys.
+func (s *Client) List(ctx context.Context, input ListInput) (*ListOutput, error) {
+	resp, err := s.backend.List(ctx, input)
+	if err != nil || s.hidden == "" || !strings.HasPrefix(s.hidden, input.Prefix) || strings.HasPrefix(input.Prefix, s.hidden) {
+		return resp, err
+	}
+
+	objects := resp.Objects[:0]
+	for _, object := range resp.Objects {
+		if !s.isHidden(object.Key) {
+			objects = append(objects, object)
+		}
+	}
+	resp.Objects = objects
+
+	prefixes := resp.CommonPrefixes[:0]
+	for _, prefix := range resp.CommonPrefixes {
+		if !s.isHidden(prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	resp.CommonPrefixes = prefixes
+
+	return resp, nil
+}
+
+// isHidden reports whether key is below the prefix passed to Hide.
+func (s *Client) isHidden(key string) bool {
+	return s.hidden != "" && strings.HasPrefix(key, s.hidden)
+}
+
+// Observe registers an Observer for the changes made through this client.
+// Observers must be registered before the client is used.
+func (s *Client) Observe(observer Observer) {
+	s.observers = append(s.observers, observer)
+}
+
+// Stored notifies the observers that the object under key was written. The
+// client calls it itself; it only needs to be called by code writing to the
+// backend directly, like multipart uploads.
+func (s *Client) Stored(ctx context.Context, key string) {
+	if len(s.observers) == 0 || s.isHidden(key) {
+		return
+	}
+
+	info, err := s.backend.Stat(ctx, key)
+	if err != nil {
+		log.Printf("Failed to stat %s/%s for observers: %s", s.name, key, err)
+		return
+	}
+
+	if tagReader, ok := s.backend.(TagReader); ok {
+		info.Tags, err = tagReader.GetTags(ctx, key)
+		if err != nil {
+			log.Printf("Failed to read tags of %s/%s for observers: %s", s.name, key, err)
+		}
+	}
+
+	for _, observer := range s.observers {
+		observer.ObjectStored(s.name, *info)
+	}
+}
+
+// removed notifies the observers that the object under key was deleted.
+func (s *Client) removed(key string) {
+	if s.isHidden(key) {
+		return
+	}
+
+	for _, observer := range s.observers {
+		observer.ObjectRemoved(s.name, key)
+	}
+}
+
+// CreateFolder creates a folder (empty object) in the specified folder path
+func (s *Client) CreateFolder(ctx context.Context, folderPath string) error {
+	// Add a trailing slash to the folder path if not already present
+	if folderPath != "" && !strings.HasSuffix(folderPath, "/") {
+		folderPath += "/"
+	}
+
+	// Create an empty object with the folder path as the key
+	if err := s.backend.Put(ctx, folderPath, strings.NewReader("")); err != nil {
+		return err
+	}
+
+	s.Stored(ctx, folderPath)
+	return nil
+}
+
+// UploadFile uploads a file to the backend.
+func (s *Client) UploadFile(ctx context.Context, src io.Reader, objectKey string) error {
+	if err := s.backend.Put(ctx, objectKey, src); err != nil {
+		return err
+	}
+
+	s.Stored(ctx, objectKey)
+	return nil
+}
+
+// ListFiles lists all the objects within a folder.
+func (s *Client) ListFiles(ctx context.Context, folderPath string, nextPageToken string, pageSize int, isFolder bool, cache *cache.URLCache) (*ListFilesResponse, error) {
+
+	// If the folder path does not end with a slash, add it
+	if (folderPath != "") && !strings.HasSuffix(folderPath, "/") {
+		folderPath += "/"
+	}
+
+	if pageSize <= 0 {
+		pageSize = DefaultMaxKeys
+	}
+
+	// The marker object of the folder itself is listed too but not returned,
+	// so when it took up a key on this page one more is fetched to fill it
+	var prefixes []string
+	var files []ObjectInfo
+	var resp *ListOutput
+
+	for token, remaining := nextPageToken, pageSize; remaining > 0; {
+		var err error
+		resp, err = s.List(ctx, ListInput{
+			Prefix:            folderPath,
+			Delimiter:         "/",
+			ContinuationToken: token,
+			MaxKeys:           remaining,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, resp.CommonPrefixes...)
+		remaining -= len(resp.CommonPrefixes)
+
+		for _, obj := range resp.Objects {
+			if obj.Key == folderPath {
+				continue // skip the folder itself
+			}
+
+			files = append(files, obj)
+			remaining--
+		}
+
+		if !resp.IsTruncated {
+			break
+		}
+		token = resp.NextContinuationToken
+	}
+
+	// send all file details
+	var objects []ObjectDetails
+
+	for _, prefix := range prefixes {
+		objects = append(objects, ObjectDetails{
+			Name:         prefix,
+			IsFolder:     true,
+			Size:         0,
+			LastModified: time.Now().UTC().Truncate(time.Second),
+		})
+	}
+
+	var fileCount int32 = 0
+
+	if !isFolder {
+		for _, obj := range files {
+			fileCount++
+			objects = append(objects, ObjectDetails{
+				Name:         obj.Key,
+				IsFolder:     strings.HasSuffix(obj.Key, "/"),
+				Size:         obj.Size,
+				LastModified: obj.LastModified,
+			})
+
+			// generate a signed download URL for the object
+			downloadURL, err := s.GenerateDownloadLink(obj.Key, cache)
+
+			if err != nil {
+				return nil, err
+			}
+
+			objects[len(objects)-1].DownloadLink = downloadURL
+		}
+	}
+
+	response := &ListFilesResponse{
+		Files:               &objects,
+		NextPageToken:       resp.NextContinuationToken,
+		IsLastPage:          !resp.IsTruncated,
+		NoOfRecordsReturned: int32(len(objects)),
+		FilesCount:          fileCount,
+		FoldersCount:        int32(len(prefixes)),
+	}
+
+	return response, nil
+}
+
+// ListFolder returns every entry directly within a folder, following the
+// backend's pagination until the end. Unlike ListFiles no download links are
+// generated, so callers can sort and filter the whole folder before picking the
+// page they need with PageFiles.
+func (s *Client) ListFolder(ctx context.Context, folderPath string, isFolder bool) ([]ObjectDetails, error) {
+	if (folderPath != "") && !strings.HasSuffix(folderPath, "/") {
+		folderPath += "/"
+	}
+
+	objects := []ObjectDetails{}
+	token := ""
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for {
+		resp, err := s.List(ctx, ListInput{
+			Prefix:            folderPath,
+			Delimiter:         "/",
+			ContinuationToken: token,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, prefix := range resp.CommonPrefixes {
+			objects = append(objects, ObjectDetails{
+				Name:         prefix,
+				IsFolder:     true,
+				LastModified: now,
+			})
+		}
+
+		if !isFolder {
+			for _, obj := range resp.Objects {
+				if obj.Key == folderPath {
+					continue // skip the folder itself
+				}
+
+				objects = append(objects, ObjectDetails{
+					Name:         obj.Key,
+					IsFolder:     strings.HasSuffix(obj.Key, "/"),
+					Size:         obj.Size,
+					LastModified: obj.LastModified,
+				})
+			}
+		}
+
+		if !resp.IsTruncated {
+			return objects, nil
+		}
+		token = resp.NextContinuationToken
+	}
+}
+
+// PageFiles returns the page starting at offset of an already sorted and
+// filtered folder listing and generates download links for the files on it.
+// Callers continue with the next page at offset plus the number of records
+// returned, as long as it isn't the last page. An offset past the end, of a
+// folder that shrank since it was issued, gives an empty last page.
+func (s *Client) PageFiles(files []ObjectDetails, offset int, pageSize int, cache *cache.URLCache) (*ListFilesResponse, error) {
+	if offset < 0 {
+		return nil, ErrInvalidToken
+	}
+	if offset > len(files) {
+		offset = len(files)
+	}
+
+	if pageSize <= 0 {
+		pageSize = DefaultMaxKeys
+	}
+
+	end := offset + pageSize
+	if end > len(files) {
+		end = len(files)
+	}
+
+	page := append([]ObjectDetails{}, files[offset:end]...)
+
+	var fileCount, folderCount int32
+	for i := range page {
+		if page[i].IsFolder && strings.HasSuffix(page[i].Name, "/") {
+			folderCount++
+			continue
+		}
+
+		fileCount++
+
+		// generate a signed download URL for the object
+		downloadURL, err := s.GenerateDownloadLink(page[i].Name, cache)
+		if err != nil {
+			return nil, err
+		}
+
+		page[i].DownloadLink = downloadURL
+	}
+
+	response := &ListFilesResponse{
+		Files:               &page,
+		IsLastPage:          end == len(files),
+		NoOfRecordsReturned: int32(len(page)),
+		FilesCount:          fileCount,
+		FoldersCount:        folderCount,
+	}
+
+	return response, nil
+}
+
+// WalkOptions restricts the objects visited by Walk.
+type WalkOptions struct {
+	// MaxDepth limits how many folders deep below the listed folder objects
+	// may be; 1 only visits its direct children. 0 means unlimited.
+	MaxDepth int
+
+	// Include and Exclude are matched against keys relative to the listed
+	// folder. An object is visited when it matches any Include pattern (or
+	// there are none) and no Exclude pattern.
+	Include []*Glob
+	Exclude []*Glob
+
+	// Filter, when set, has to match as well
+	Filter Matcher
+}
+
+// Walk calls fn for every object below a folder, in key order. It uses a flat
+// listing without delimiter, so the number of requests only depends on the
+// number of objects and not on how deeply they are nested, and only one page
+// is held in memory at a time. Walking stops at the first error fn returns.
+func (s *Client) Walk(ctx context.Context, folderPath string, options WalkOptions, fn func(ObjectDetails) error) error {
+	if (folderPath != "") && !strings.HasSuffix(folderPath, "/") {
+		folderPath += "/"
+	}
+
+	token := ""
+	for {
+		resp, err := s.List(ctx, ListInput{
+			Prefix:            folderPath,
+			ContinuationToken: token,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range resp.Objects {
+			name := strings.TrimPrefix(obj.Key, folderPath)
+			if name == "" || !options.matches(name) {
+				continue // skip the folder itself
+			}
+
+			object := ObjectDetails{
+				Name:         obj.Key,
+				IsFolder:     strings.HasSuffix(obj.Key, "/"),
+				Size:         obj.Size,
+				LastModified: obj.LastModified,
+			}
+
+			if options.Filter != nil && !options.Filter.Match(object) {
+				continue
+			}
+
+			if err := fn(object); err != nil {
+				return err
+			}
+		}
+
+		if !resp.IsTruncated {
+			return nil
+		}
+		token = resp.NextContinuationToken
+	}
+}
+
+// matches reports whether a key relative to the walked folder passes the
+// depth limit and the include and exclude patterns.
+func (options WalkOptions) matches(name string) bool {
+	if options.MaxDepth > 0 && strings.Count(strings.TrimSuffix(name, "/"), "/") >= options.MaxDepth {
+		return false
+	}
+
+	for _, glob := range options.Exclude {
+		if glob.Match(strings.TrimSuffix(name, "/")) {
+			return false
+		}
+	}
+
+	if len(options.Include) == 0 {
+		return true
+	}
+
+	for _, glob := range options.Include {
+		if glob.Match(strings.TrimSuffix(name, "/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetFile retrieves a file from the backend. The caller must close the reader.
+func (s *Client) GetFile(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.backend.Get(ctx, key)
+}
+
+// Function to generate a signed download URL for the object
+func (s *Client) GenerateDownloadLink(objectKey string, cache *cache.URLCache) (string, error) {
+	// links are cached per bucket, the same key can exist in several
+	cacheKey := s.name + "/" + objectKey
+	url, found := cache.Get(cacheKey)
+
+	// Check if the URL is already in the cache and valid
+	if found {
+		return url, nil
+	}
+
+	expiryTime := 15 * time.Minute
+
+	downloadURL, err := s.backend.Presign(objectKey, expiryTime) // Set the validity period of the signed URL
+	if err != nil {
+		return "", err
+	}
+
+	// Cache the URL with its expiration time
+	cache.Set(cacheKey, downloadURL, time.Now().Add(expiryTime))
+
+	return downloadURL, nil
+}
+
+// DeleteObject deletes an object from the backend.
+func (s *Client) DeleteObject(ctx context.Context, objectKey string) error {
+	if err := s.backend.Delete(ctx, objectKey); err != nil {
+		return err
+	}
+
+	s.removed(objectKey)
+	return nil
+}
+
+// ListAllFolders lists all the folders below a folder, recursively. Folders
+// are taken from folder markers and from the prefixes of the keys, so folders
+// that only exist implicitly are included and empty files are not.
+func (s *Client) ListAllFolders(ctx context.Context, folderPath string) ([]ObjectDetails, error) {
+	// add a trailing slash to the folder path if not already present
+	if folderPath != "" && !strings.HasSuffix(folderPath, "/") {
+		folderPath += "/"
+	}
+
+	allObjects := []ObjectDetails{}
+	seen := make(map[string]struct{})
+
+	err := s.Walk(ctx, folderPath, WalkOptions{}, func(object ObjectDetails) error {
+		name := strings.TrimPrefix(object.Name, folderPath)
+
+		for i := 0; i < len(name); i++ {
+			if name[i] != '/' {
+				continue
+			}
+
+			key := folderPath + name[:i+1]
+			if _, found := seen[key]; found {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			folder := ObjectDetails{Name: key, IsFolder: true}
+			if key == object.Name {
+				folder.LastModified = object.LastModified
+			}
+			allObjects = append(allObjects, folder)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return allObjects, nil
+}
@@ -0,0 +1,163 @@
+package storage_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"file-management-service/config"
+	"file-management-service/pkg/cache"
+	"file-management-service/pkg/memory"
+	"file-management-service/pkg/storage"
+)
+
+// newMemoryClient returns a client of an in-memory bucket holding keys, each
+// with its key as content.
+func newMemoryClient(t *testing.T, keys ...string) *storage.Client {
+	t.Helper()
+
+	backend, err := memory.NewClient(&config.Config{Name: "test", PublicURL: "http://localhost", URLSigningKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range keys {
+		if err := backend.Put(context.Background(), key, strings.NewReader(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return storage.NewClient("test", backend)
+}
+
+func names(files []storage.ObjectDetails) []string {
+	result := []string{}
+	for _, file := range files {
+		result = append(result, file.Name)
+	}
+	return result
+}
+
+func TestListFiles(t *testing.T) {
+	client := newMemoryClient(t,
+		"a.txt",
+		"docs/",
+		"docs/a.txt",
+		"docs/b/c.txt",
+		"docs/d.txt",
+		"photos/1.png",
+		"z.txt",
+	)
+
+	tests := []struct {
+		name     string
+		folder   string
+		isFolder bool
+		files    []string
+		folders  int32
+	}{
+		{
+			name:    "root",
+			folder:  "",
+			files:   []string{"docs/", "photos/", "a.txt", "z.txt"},
+			folders: 2,
+		},
+		{
+			name:    "folder without the marker",
+			folder:  "docs",
+			files:   []string{"docs/b/", "docs/a.txt", "docs/d.txt"},
+			folders: 1,
+		},
+		{
+			name:     "only folders",
+			folder:   "",
+			isFolder: true,
+			files:    []string{"docs/", "photos/"},
+			folders:  2,
+		},
+		{
+			name:   "missing folder",
+			folder: "missing/",
+			files:  []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := client.ListFiles(context.Background(), test.folder, "", 0, test.isFolder, cache.NewURLCache())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := names(*resp.Files); !reflect.DeepEqual(got, test.files) {
+				t.Errorf("files = %q, want %q", got, test.files)
+			}
+			if resp.FoldersCount != test.folders {
+				t.Errorf("folders count = %d, want %d", resp.FoldersCount, test.folders)
+			}
+			if !resp.IsLastPage || resp.NextPageToken != "" {
+				t.Errorf("single page listing is not the last page")
+			}
+
+			for _, file := range *resp.Files {
+				if !file.IsFolder && file.DownloadLink == "" {
+					t.Errorf("%s has no download link", file.Name)
+				}
+			}
+		})
+	}
+}
+
+// TestListFilesPages checks that the folder marker does not take up a place
+// on a page, and that the pages add up to the whole folder.
+func TestListFilesPages(t *testing.T) {
+	client := newMemoryClient(t,
+		"docs/",
+		"docs/a.txt",
+		"docs/b/c.txt",
+		"docs/d.txt",
+		"docs/e.txt",
+	)
+
+	want := []string{"docs/a.txt", "docs/b/", "docs/d.txt", "docs/e.txt"}
+
+	for pageSize := 1; pageSize <= len(want)+1; pageSize++ {
+		var got []string
+		token := ""
+
+		for pages := 0; ; pages++ {
+			if pages > len(want) {
+				t.Fatalf("page size %d: too many pages", pageSize)
+			}
+
+			resp, err := client.ListFiles(context.Background(), "docs/", token, pageSize, false, cache.NewURLCache())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			page := names(*resp.Files)
+			if len(page) > pageSize {
+				t.Errorf("page size %d: page holds %q", pageSize, page)
+			}
+			if !resp.IsLastPage && len(page) != pageSize {
+				t.Errorf("page size %d: page before the last one holds %q", pageSize, page)
+			}
+
+			// folders come first on each page, so only the pages as a whole
+			// are compared
+			got = append(got, page...)
+
+			if resp.IsLastPage {
+				break
+			}
+			token = resp.NextPageToken
+		}
+
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("page size %d: pages hold %q, want %q", pageSize, got, want)
+		}
+	}
+}
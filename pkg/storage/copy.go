@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"file-management-service/pkg/cache"
+)
+
+// CopyOptions changes what a copy keeps of its source. The zero value keeps
+// everything the destination backend can store.
+type CopyOptions struct {
+	// ReplaceMetadata replaces the user metadata of the source with Metadata,
+	// and its content type with ContentType when that is set.
+	ReplaceMetadata bool              `json:"replaceMetadata,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	ContentType     string            `json:"contentType,omitempty"`
+
+	// StorageClass is the storage class of the copy, the source's if empty.
+	StorageClass string `json:"storageClass,omitempty"`
+}
+
+// Apply returns info as the copy should store it.
+func (options CopyOptions) Apply(info ObjectInfo) ObjectInfo {
+	if options.ReplaceMetadata {
+		info.Metadata = options.Metadata
+		if options.ContentType != "" {
+			info.ContentType = options.ContentType
+		}
+	}
+
+	if options.StorageClass != "" {
+		info.StorageClass = options.StorageClass
+	}
+
+	return info
+}
+
+// changes reports whether a copy with these options differs from its source
+// in more than the key.
+func (options CopyOptions) changes() bool {
+	return options.ReplaceMetadata || options.StorageClass != ""
+}
+
+// RemoteCopier is implemented by backends that can copy objects from another
+// backend without downloading them, like S3 buckets reachable with the same
+// credentials.
+type RemoteCopier interface {
+	CanCopyFrom(source Storage) bool
+	CopyFrom(ctx context.Context, source Storage, key string, destination string, options CopyOptions) error
+}
+
+// ObjectWriter is implemented by backends that store content type, metadata,
+// tags and storage class along with an object. Other backends only keep the
+// content when an object is copied into them.
+type ObjectWriter interface {
+	PutObject(ctx context.Context, key string, body io.Reader, info ObjectInfo) error
+}
+
+// Copy copies an object to destination in the bucket of target, which may be
+// this client, and returns the key the copy ended up at. A destination ending
+// with a slash is a folder the object is copied into under its current name.
+func (s *Client) Copy(ctx context.Context, source string, target *Client, destination string, policy ConflictPolicy, options CopyOptions, cache *cache.URLCache) (string, error) {
+	if source == "" || strings.HasSuffix(source, "/") {
+		return "", fmt.Errorf("invalid source %q, only files can be copied", source)
+	}
+
+	if destination == "" || strings.HasSuffix(destination, "/") {
+		destination += path.Base(source)
+	}
+
+	info, err := s.backend.Stat(ctx, source)
+	if err != nil {
+		return "", err
+	}
+
+	return s.CopyObject(ctx, *info, target, destination, policy, options, cache)
+}
+
+// CopyObject copies the object described by source to destination in the
+// bucket of target. The copy is verified against the source before it is
+// reported as stored.
+func (s *Client) CopyObject(ctx context.Context, source ObjectInfo, target *Client, destination string, policy ConflictPolicy, options CopyOptions, cache *cache.URLCache) (string, error) {
+	// copying an object onto itself only changes its metadata or storage
+	// class, and only on backends that can copy server side
+	if target == s && destination == source.Key {
+		if policy != ConflictOverwrite {
+			return "", ErrExists
+		}
+
+		if _, ok := s.backend.(Copier); !ok || !options.changes() {
+			return destination, nil
+		}
+	} else {
+		var err error
+		destination, err = target.FreeName(ctx, destination, policy)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err := s.copyObject(ctx, source, target, destination, options); err != nil {
+		return "", err
+	}
+
+	if err := target.verify(ctx, source, destination); err != nil {
+		return "", err
+	}
+	target.Stored(ctx, destination)
+
+	cache.Delete(target.name + "/" + destination)
+
+	return destination, nil
+}
+
+// copyObject copies an object to another key, server side where the backends
+// support it and by reading and writing it again otherwise.
+func (s *Client) copyObject(ctx context.Context, source ObjectInfo, target *Client, destination string, options CopyOptions) error {
+	if copier, ok := s.backend.(Copier); ok && target == s {
+		return copier.Copy(ctx, source.Key, destination, options)
+	}
+
+	if copier, ok := target.backend.(RemoteCopier); ok && copier.CanCopyFrom(s.backend) {
+		return copier.CopyFrom(ctx, s.backend, source.Key, destination, options)
+	}
+
+	body, err := s.backend.Get(ctx, source.Key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	writer, ok := target.backend.(ObjectWriter)
+	if !ok {
+		return target.backend.Put(ctx, destination, body)
+	}
+
+	// the tags take a request of their own and are not part of the info
+	if tagReader, ok := s.backend.(TagReader); ok && source.Tags == nil {
+		source.Tags, err = tagReader.GetTags(ctx, source.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.PutObject(ctx, destination, body, options.Apply(source))
+}
+
+// FreeName applies policy to a destination that may exist already and returns
+// the key to write to.
+func (s *Client) FreeName(ctx context.Context, destination string, policy ConflictPolicy) (string, error) {
+	exists, err := s.exists(ctx, destination)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return destination, nil
+	}
+
+	switch policy {
+	case ConflictOverwrite:
+		return destination, nil
+	case ConflictRename:
+		ext := path.Ext(destination)
+		base := strings.TrimSuffix(destination, ext)
+
+		for i := 1; i <= maxRenameAttempts; i++ {
+			candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+
+			exists, err := s.exists(ctx, candidate)
+			if err != nil {
+				return "", err
+			}
+			if !exists {
+				return candidate, nil
+			}
+		}
+
+		return "", fmt.Errorf("no free name found for %s after %d attempts", destination, maxRenameAttempts)
+	}
+
+	return "", ErrExists
+}
+
+// exists reports whether an object is stored under key.
+func (s *Client) exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.backend.Stat(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
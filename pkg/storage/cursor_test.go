@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestSigner(t *testing.T, secret string) *CursorSigner {
+	t.Helper()
+
+	signer, err := NewCursorSigner(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	signer := newTestSigner(t, "secret")
+
+	for _, cursor := range []Cursor{
+		{Bucket: "main", Query: "path=docs%2F&sortBy=size"},
+		{Bucket: "main", Query: "path=docs%2F", Offset: 200},
+		{Bucket: "archive", Query: "", Token: "ZG9jcy9hLnR4dA"},
+	} {
+		decoded, err := signer.Decode(signer.Encode(cursor))
+		if err != nil {
+			t.Fatalf("%+v: %v", cursor, err)
+		}
+		if *decoded != cursor {
+			t.Errorf("decoded %+v, want %+v", *decoded, cursor)
+		}
+	}
+}
+
+func TestCursorTampering(t *testing.T) {
+	signer := newTestSigner(t, "secret")
+	encoded := signer.Encode(Cursor{Bucket: "main", Query: "path=docs%2F", Offset: 100})
+	payload, signature, _ := strings.Cut(encoded, ".")
+
+	// the same cursor with another offset, signed with the original signature
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"b":"main","q":"path=docs%2F","o":0}`))
+
+	// a cursor signed with the right key that could not have been issued
+	negative := signer.Encode(Cursor{Bucket: "main", Offset: -1})
+
+	tests := map[string]string{
+		"empty":             "",
+		"no signature":      payload,
+		"forged payload":    forged + "." + signature,
+		"changed signature": payload + "." + strings.Repeat("A", len(signature)),
+		"invalid base64":    payload + ".!!",
+		"other key":         newTestSigner(t, "other").Encode(Cursor{Bucket: "main", Query: "path=docs%2F", Offset: 100}),
+		"negative offset":   negative,
+	}
+
+	for name, value := range tests {
+		if _, err := signer.Decode(value); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+// TestCursorKeyIsDerived checks that a cursor cannot be built from a
+// download link signature, which is made with the same secret.
+func TestCursorKeyIsDerived(t *testing.T) {
+	signer := newTestSigner(t, "secret")
+	if string(signer.signingKey) == "secret" {
+		t.Error("cursors are signed with the secret itself")
+	}
+}
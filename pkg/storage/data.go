@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"time"
+)
+
+var sizeRanges = map[string]FilterSizeRange{
+	"0-10MB":    {0, 10 * 1024 * 1024},
+	"10-100MB":  {10 * 1024 * 1024, 100 * 1024 * 1024},
+	"100MB-1GB": {100 * 1024 * 1024, 1024 * 1024 * 1024},
+	"1GB-10GB":  {1024 * 1024 * 1024, 10 * 1024 * 1024 * 1024},
+	"10GB+":     {10 * 1024 * 1024 * 1024, -1}, // -1 represents unlimited size
+}
+
+// timeRanges resolve a named range to the [from, to) interval it covers, with
+// now in the caller's time zone. A zero to leaves the range open ended.
+// Calendar ranges start at midnight, and weeks start on Monday.
+var timeRanges = map[string]func(now time.Time) (time.Time, time.Time){
+	"today": func(now time.Time) (time.Time, time.Time) {
+		return startOfDay(now), time.Time{}
+	},
+	"yesterday": func(now time.Time) (time.Time, time.Time) {
+		today := startOfDay(now)
+		return today.AddDate(0, 0, -1), today
+	},
+	"this week": func(now time.Time) (time.Time, time.Time) {
+		return startOfWeek(now), time.Time{}
+	},
+	"last week": func(now time.Time) (time.Time, time.Time) {
+		week := startOfWeek(now)
+		return week.AddDate(0, 0, -7), week
+	},
+	"this month": func(now time.Time) (time.Time, time.Time) {
+		return startOfMonth(now), time.Time{}
+	},
+	"last month": func(now time.Time) (time.Time, time.Time) {
+		month := startOfMonth(now)
+		return month.AddDate(0, -1, 0), month
+	},
+	"this year": func(now time.Time) (time.Time, time.Time) {
+		return time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location()), time.Time{}
+	},
+	"last year": func(now time.Time) (time.Time, time.Time) {
+		year := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+		return year.AddDate(-1, 0, 0), year
+	},
+	"last 7 days": func(now time.Time) (time.Time, time.Time) {
+		return now.AddDate(0, 0, -7), time.Time{}
+	},
+	"last 30 days": func(now time.Time) (time.Time, time.Time) {
+		return now.AddDate(0, 0, -30), time.Time{}
+	},
+	"last 90 days": func(now time.Time) (time.Time, time.Time) {
+		return now.AddDate(0, 0, -90), time.Time{}
+	},
+	"last 1 year": func(now time.Time) (time.Time, time.Time) {
+		return now.AddDate(-1, 0, 0), time.Time{}
+	},
+
+	// custom only uses the explicit ModifiedFrom and ModifiedTo bounds
+	"custom": func(now time.Time) (time.Time, time.Time) {
+		return time.Time{}, time.Time{}
+	},
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func startOfWeek(t time.Time) time.Time {
+	// days since Monday
+	offset := (int(t.Weekday()) + 6) % 7
+	return startOfDay(t).AddDate(0, 0, -offset)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MaxDeleteBatch is the largest number of keys passed to DeleteBatch.
+const MaxDeleteBatch = 1000
+
+// deleteWorkers bounds the batches deleted concurrently.
+const deleteWorkers = 8
+
+// maxDeleteErrors bounds the errors kept in a DeleteResult; Failed is always
+// exact.
+const maxDeleteErrors = 1000
+
+// ErrRootFolder is returned for a delete of the root of the bucket, which
+// would delete every object in it.
+var ErrRootFolder = errors.New("the root of the bucket cannot be deleted")
+
+// BatchDeleter is implemented by backends that delete many objects with one
+// request, like S3 DeleteObjects. Other backends delete one key at a time.
+type BatchDeleter interface {
+	// DeleteBatch deletes up to MaxDeleteBatch keys and returns the keys it
+	// could not delete. The error is only set when the whole request failed.
+	DeleteBatch(ctx context.Context, keys []string) ([]DeleteError, error)
+}
+
+// DeleteError is a key that could not be deleted.
+type DeleteError struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// DeleteResult is the outcome of deleting many objects.
+type DeleteResult struct {
+	Deleted int64         `json:"deleted"`
+	Failed  int64         `json:"failed"`
+	Errors  []DeleteError `json:"errors,omitempty"`
+
+	// mutex guards the result while batches are being deleted
+	mutex sync.Mutex
+}
+
+// BulkDelete deletes keys and every object whose key starts with one of
+// prefixes. Objects are deleted in batches of MaxDeleteBatch, deleteWorkers
+// batches at a time, while the prefixes are still being listed. Folder
+// markers are deleted last, nested folders before their parents, so backends
+// where folders are real directories can remove them once they are empty.
+// Keys that cannot be deleted are reported in the result; the error is only
+// set when a prefix could not be listed.
+func (s *Client) BulkDelete(ctx context.Context, keys []string, prefixes []string) (*DeleteResult, error) {
+	// every key is deleted once, even where the prefixes and keys overlap
+	prefixes = outermost(prefixes)
+	covered := func(key string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	result := &DeleteResult{}
+	files := s.newDeleter(ctx, deleteWorkers, result)
+	markers := map[string]bool{}
+
+	add := func(key string) {
+		if strings.HasSuffix(key, "/") {
+			markers[key] = true
+		} else {
+			files.add(key)
+		}
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !seen[key] && !covered(key) {
+			seen[key] = true
+			add(key)
+		}
+	}
+
+	var listErr error
+	for _, prefix := range prefixes {
+		if listErr = s.listKeys(ctx, prefix, add); listErr != nil {
+			break
+		}
+	}
+	files.close()
+
+	// a failed listing leaves the folders in place
+	if listErr != nil {
+		return result, listErr
+	}
+
+	sorted := make([]string, 0, len(markers))
+	for marker := range markers {
+		sorted = append(sorted, marker)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+
+	folders := s.newDeleter(ctx, 1, result)
+	for _, marker := range sorted {
+		folders.add(marker)
+	}
+	folders.close()
+
+	return result, nil
+}
+
+// DeleteFolder deletes a folder and its contents recursively.
+func (s *Client) DeleteFolder(ctx context.Context, folderPath string) (*DeleteResult, error) {
+	if folderPath == "" || folderPath == "/" {
+		return nil, ErrRootFolder
+	}
+
+	// add a trailing slash to the folder path if not already present
+	if !strings.HasSuffix(folderPath, "/") {
+		folderPath += "/"
+	}
+
+	return s.BulkDelete(ctx, nil, []string{folderPath})
+}
+
+// outermost drops the prefixes that start with another one of prefixes.
+func outermost(prefixes []string) []string {
+	sorted := append([]string(nil), prefixes...)
+	sort.Strings(sorted)
+
+	var result []string
+	for _, prefix := range sorted {
+		if len(result) == 0 || !strings.HasPrefix(prefix, result[len(result)-1]) {
+			result = append(result, prefix)
+		}
+	}
+
+	return result
+}
+
+// listKeys calls fn for every key starting with prefix.
+func (s *Client) listKeys(ctx context.Context, prefix string, fn func(key string)) error {
+	nextToken := ""
+
+	for {
+		resp, err := s.List(ctx, ListInput{
+			Prefix:            prefix,
+			MaxKeys:           MaxDeleteBatch,
+			ContinuationToken: nextToken,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range resp.Objects {
+			fn(obj.Key)
+		}
+
+		nextToken = resp.NextContinuationToken
+		if !resp.IsTruncated || nextToken == "" {
+			return nil
+		}
+	}
+}
+
+// deleter collects keys into batches and deletes them in the background.
+type deleter struct {
+	client  *Client
+	ctx     context.Context
+	batch   []string
+	batches chan []string
+	wg      sync.WaitGroup
+	result  *DeleteResult
+}
+
+// newDeleter starts a deleter with workers goroutines adding its outcome to
+// result. Batches are deleted in the order they were added when workers is 1.
+func (s *Client) newDeleter(ctx context.Context, workers int, result *DeleteResult) *deleter {
+	d := &deleter{
+		client:  s,
+		ctx:     ctx,
+		batches: make(chan []string),
+		result:  result,
+	}
+
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for batch := range d.batches {
+				d.delete(batch)
+			}
+		}()
+	}
+
+	return d
+}
+
+// add queues a key, and hands the batch to the workers once it is full.
+func (d *deleter) add(key string) {
+	d.batch = append(d.batch, key)
+	if len(d.batch) == MaxDeleteBatch {
+		d.batches <- d.batch
+		d.batch = nil
+	}
+}
+
+// close deletes the remaining keys and waits for the workers.
+func (d *deleter) close() {
+	if len(d.batch) > 0 {
+		d.batches <- d.batch
+		d.batch = nil
+	}
+
+	close(d.batches)
+	d.wg.Wait()
+}
+
+// delete deletes one batch and records the outcome.
+func (d *deleter) delete(batch []string) {
+	failures := d.client.deleteBatch(d.ctx, batch)
+
+	failed := make(map[string]bool, len(failures))
+	for _, failure := range failures {
+		failed[failure.Key] = true
+	}
+
+	for _, key := range batch {
+		if !failed[key] {
+			d.client.removed(key)
+		}
+	}
+
+	d.result.mutex.Lock()
+	defer d.result.mutex.Unlock()
+
+	d.result.Deleted += int64(len(batch) - len(failures))
+	d.result.Failed += int64(len(failures))
+	for _, failure := range failures {
+		if len(d.result.Errors) < maxDeleteErrors {
+			d.result.Errors = append(d.result.Errors, failure)
+		}
+	}
+}
+
+// deleteBatch deletes keys with one request where the backend supports it,
+// and one by one in the given order otherwise.
+func (s *Client) deleteBatch(ctx context.Context, keys []string) []DeleteError {
+	if batchDeleter, ok := s.backend.(BatchDeleter); ok {
+		failures, err := batchDeleter.DeleteBatch(ctx, keys)
+		if err != nil {
+			failures = make([]DeleteError, len(keys))
+			for i, key := range keys {
+				failures[i] = DeleteError{Key: key, Error: err.Error()}
+			}
+		}
+		return failures
+	}
+
+	var failures []DeleteError
+	for _, key := range keys {
+		if err := s.backend.Delete(ctx, key); err != nil {
+			failures = append(failures, DeleteError{Key: key, Error: err.Error()})
+		}
+	}
+
+	return failures
+}
@@ -0,0 +1,90 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"file-management-service/pkg/storage"
+)
+
+// keys lists every key of the bucket.
+func keys(t *testing.T, client *storage.Client) []string {
+	t.Helper()
+
+	resp, err := client.List(context.Background(), storage.ListInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := []string{}
+	for _, object := range resp.Objects {
+		result = append(result, object.Key)
+	}
+	return result
+}
+
+func TestBulkDelete(t *testing.T) {
+	client := newMemoryClient(t,
+		"a.txt",
+		"b.txt",
+		"docs/",
+		"docs/a.txt",
+		"docs/b/",
+		"docs/b/c.txt",
+		"docsx/a.txt",
+	)
+
+	// keys below a prefix and repeated ones are deleted once
+	result, err := client.BulkDelete(context.Background(),
+		[]string{"a.txt", "a.txt", "docs/a.txt", "missing.txt"},
+		[]string{"docs/", "docs/b/"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Deleted != 6 || result.Failed != 0 {
+		t.Errorf("deleted %d, failed %d; want 6 and 0", result.Deleted, result.Failed)
+	}
+
+	if got, want := keys(t, client), []string{"b.txt", "docsx/a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("left %q, want %q", got, want)
+	}
+}
+
+func TestBulkDeleteBatches(t *testing.T) {
+	client := newMemoryClient(t)
+	for i := 0; i < 2*storage.MaxDeleteBatch+10; i++ {
+		put(t, client, fmt.Sprintf("many/%05d", i), "x")
+	}
+	put(t, client, "keep.txt", "x")
+
+	result, err := client.DeleteFolder(context.Background(), "many")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Deleted != 2*storage.MaxDeleteBatch+10 {
+		t.Errorf("deleted %d objects", result.Deleted)
+	}
+	if got := keys(t, client); !reflect.DeepEqual(got, []string{"keep.txt"}) {
+		t.Errorf("left %q", got)
+	}
+}
+
+func TestDeleteFolderRoot(t *testing.T) {
+	client := newMemoryClient(t, "a.txt")
+
+	for _, folder := range []string{"", "/"} {
+		if _, err := client.DeleteFolder(context.Background(), folder); !errors.Is(err, storage.ErrRootFolder) {
+			t.Errorf("%q: err = %v, want ErrRootFolder", folder, err)
+		}
+	}
+
+	if got := keys(t, client); len(got) != 1 {
+		t.Errorf("left %q, want a.txt", got)
+	}
+}
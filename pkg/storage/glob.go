@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Glob matches object keys against a shell style pattern. "*" and "?" do not
+// match "/", "**" matches any number of path segments and "[...]" matches a
+// character class. Patterns without a "/" are matched against the last path
+// segment only, so "*.csv" selects CSV files at any depth.
+type Glob struct {
+	pattern  string
+	baseName bool
+	re       *regexp.Regexp
+}
+
+// CompileGlob parses a glob pattern.
+func CompileGlob(pattern string) (*Glob, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				// "**/" also matches no folder at all
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					expr.WriteString("(?:.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid glob %q: unterminated character class", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+	}
+
+	return &Glob{
+		pattern:  pattern,
+		baseName: !strings.Contains(pattern, "/"),
+		re:       re,
+	}, nil
+}
+
+// Match reports whether name, a key relative to the listed folder, matches.
+func (g *Glob) Match(name string) bool {
+	if g.baseName {
+		name = path.Base(name)
+	}
+
+	return g.re.MatchString(name)
+}
+
+// String returns the original pattern.
+func (g *Glob) String() string {
+	return g.pattern
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"file-management-service/pkg/cache"
+)
+
+// ErrExists is returned when the destination of a move or copy already exists and
+// the conflict policy does not allow replacing it.
+var ErrExists = errors.New("destination already exists")
+
+// ConflictPolicy decides what happens when the destination of a move or copy
+// already exists.
+type ConflictPolicy string
+
+const (
+	// ConflictFail leaves both objects alone and fails with ErrExists.
+	ConflictFail ConflictPolicy = "fail"
+
+	// ConflictOverwrite replaces the existing object.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+
+	// ConflictRename picks the first free name of the form "name (n).ext".
+	ConflictRename ConflictPolicy = "rename"
+)
+
+// ErrVerifyFailed is returned when a copy does not match its source.
+var ErrVerifyFailed = errors.New("copy verification failed")
+
+// md5ETag matches ETags that are the MD5 of the object's content.
+var md5ETag = regexp.MustCompile(`^"?[0-9a-fA-F]{32}"?$`)
+
+// maxRenameAttempts bounds the search for a free name by ConflictRename.
+const maxRenameAttempts = 1000
+
+// ParseConflictPolicy parses a conflict policy, ConflictFail by default.
+func ParseConflictPolicy(value string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(value); policy {
+	case "":
+		return ConflictFail, nil
+	case ConflictFail, ConflictOverwrite, ConflictRename:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("invalid conflict policy %q, expected fail, overwrite or rename", value)
+}
+
+// Move moves an object to a new key and returns the key it ended up at. A
+// destination ending with a slash is a folder the object is moved into under
+// its current name.
+func (s *Client) Move(ctx context.Context, source string, destination string, policy ConflictPolicy, cache *cache.URLCache) (string, error) {
+	if source == "" || strings.HasSuffix(source, "/") {
+		return "", fmt.Errorf("invalid source %q, only files can be moved", source)
+	}
+
+	if destination == "" || strings.HasSuffix(destination, "/") {
+		destination += path.Base(source)
+	}
+
+	info, err := s.backend.Stat(ctx, source)
+	if err != nil {
+		return "", err
+	}
+
+	return s.MoveObject(ctx, *info, destination, policy, cache)
+}
+
+// MoveObject moves the object described by source to destination. The object
+// is copied first, server side where the backend supports it, and the source
+// is only deleted once the copy has been verified.
+func (s *Client) MoveObject(ctx context.Context, source ObjectInfo, destination string, policy ConflictPolicy, cache *cache.URLCache) (string, error) {
+	// moving an object onto itself leaves it where it is
+	if destination == source.Key {
+		return destination, nil
+	}
+
+	destination, err := s.FreeName(ctx, destination, policy)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.copyObject(ctx, source, s, destination, CopyOptions{}); err != nil {
+		return "", err
+	}
+
+	if err := s.verify(ctx, source, destination); err != nil {
+		return "", err
+	}
+
+	return s.finishMove(ctx, source, destination, cache)
+}
+
+// finishMove deletes the source of a move once it has been copied.
+func (s *Client) finishMove(ctx context.Context, source ObjectInfo, destination string, cache *cache.URLCache) (string, error) {
+	s.Stored(ctx, destination)
+
+	if err := s.backend.Delete(ctx, source.Key); err != nil {
+		return destination, fmt.Errorf("copied to %s but failed to delete the source: %w", destination, err)
+	}
+	s.removed(source.Key)
+
+	// the cached link of the old key would point at a missing object, and
+	// links of backends serving objects themselves check the key
+	cache.Delete(s.name + "/" + source.Key)
+	cache.Delete(s.name + "/" + destination)
+
+	return destination, nil
+}
+
+// verify checks that the copy at destination matches the source.
+func (s *Client) verify(ctx context.Context, source ObjectInfo, destination string) error {
+	info, err := s.backend.Stat(ctx, destination)
+	if err != nil {
+		return fmt.Errorf("failed to verify the copy at %s: %w", destination, err)
+	}
+
+	if !identical(source, *info) {
+		return fmt.Errorf("%w: %s does not match %s", ErrVerifyFailed, destination, source.Key)
+	}
+
+	return nil
+}
+
+// identical checks a fresh copy against its source: by size, and by content
+// hash where both ETags are one. Multipart uploads, objects encrypted with
+// KMS and file system backends have ETags that are not derived from the
+// content alone, so those only compare by size.
+func identical(a ObjectInfo, b ObjectInfo) bool {
+	if a.Size != b.Size {
+		return false
+	}
+
+	hashA, okA := a.contentHash()
+	hashB, okB := b.contentHash()
+	if okA && okB {
+		return hashA == hashB
+	}
+
+	return true
+}
+
+// contentHash returns the MD5 of the object's content, if its ETag is one.
+func (info ObjectInfo) contentHash() (string, bool) {
+	if info.ServerSideEncryption == "aws:kms" || !md5ETag.MatchString(info.ETag) {
+		return "", false
+	}
+
+	return strings.ToLower(strings.Trim(info.ETag, `"`)), true
+}
@@ -0,0 +1,85 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"file-management-service/pkg/cache"
+	"file-management-service/pkg/storage"
+)
+
+// content returns the content stored under key, or "" when there is none.
+func content(t *testing.T, client *storage.Client, key string) string {
+	t.Helper()
+
+	body, err := client.GetFile(context.Background(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func put(t *testing.T, client *storage.Client, key string, data string) {
+	t.Helper()
+
+	if err := client.UploadFile(context.Background(), strings.NewReader(data), key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMoveConflicts(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      storage.ConflictPolicy
+		existing    string
+		err         error
+		destination string
+	}{
+		{name: "free destination", policy: storage.ConflictFail, destination: "b.txt"},
+		{name: "fail on identical content", policy: storage.ConflictFail, existing: "a", err: storage.ErrExists},
+		{name: "fail on other content", policy: storage.ConflictFail, existing: "b", err: storage.ErrExists},
+		{name: "rename next to identical content", policy: storage.ConflictRename, existing: "a", destination: "b (1).txt"},
+		{name: "overwrite", policy: storage.ConflictOverwrite, existing: "b", destination: "b.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newMemoryClient(t)
+			put(t, client, "a.txt", "a")
+			if test.existing != "" {
+				put(t, client, "b.txt", test.existing)
+			}
+
+			moved, err := client.Move(context.Background(), "a.txt", "b.txt", test.policy, cache.NewURLCache())
+			if !errors.Is(err, test.err) {
+				t.Fatalf("err = %v, want %v", err, test.err)
+			}
+
+			if test.err != nil {
+				if content(t, client, "a.txt") != "a" || content(t, client, "b.txt") != test.existing {
+					t.Error("a failed move changed the source or the destination")
+				}
+				return
+			}
+
+			if moved != test.destination {
+				t.Errorf("moved to %s, want %s", moved, test.destination)
+			}
+			if content(t, client, moved) != "a" || content(t, client, "a.txt") != "" {
+				t.Error("the object was not moved")
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidToken is returned when a continuation token cannot be decoded.
+var ErrInvalidToken = errors.New("invalid continuation token")
+
+// DefaultMaxKeys is the page size used when ListInput.MaxKeys is not set,
+// matching the ListObjectsV2 default.
+const DefaultMaxKeys = 1000
+
+// Paginate applies ListObjectsV2 semantics (prefix filtering, delimiter
+// grouping into CommonPrefixes and continuation tokens) to a full listing.
+// Backends that can enumerate all of their keys cheaply use it to implement
+// List. objects must be sorted by key.
+func Paginate(objects []ObjectInfo, input ListInput) (*ListOutput, error) {
+	startAfter, err := StartAfter(input.ContinuationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	maxKeys := input.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxKeys
+	}
+
+	out := &ListOutput{}
+	count := 0
+	last := ""
+
+	i := sort.Search(len(objects), func(i int) bool {
+		return objects[i].Key >= input.Prefix
+	})
+
+	for ; i < len(objects); i++ {
+		key := objects[i].Key
+		if !strings.HasPrefix(key, input.Prefix) {
+			break
+		}
+
+		// keys sharing everything up to the next delimiter are rolled up into
+		// a single common prefix entry
+		entry := key
+		isPrefix := false
+		if input.Delimiter != "" {
+			if idx := strings.Index(key[len(input.Prefix):], input.Delimiter); idx >= 0 {
+				entry = key[:len(input.Prefix)+idx+len(input.Delimiter)]
+				isPrefix = true
+			}
+		}
+
+		if (startAfter != "" && entry <= startAfter) || (count > 0 && entry == last) {
+			continue
+		}
+
+		if count == maxKeys {
+			out.IsTruncated = true
+			out.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+			break
+		}
+
+		if isPrefix {
+			out.CommonPrefixes = append(out.CommonPrefixes, entry)
+		} else {
+			out.Objects = append(out.Objects, objects[i])
+		}
+
+		last = entry
+		count++
+	}
+
+	return out, nil
+}
+
+// StartAfter returns the key after which the listing continued by a token
+// from Paginate resumes, "" for no token.
+func StartAfter(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(decoded) == 0 {
+		return "", ErrInvalidToken
+	}
+
+	return string(decoded), nil
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var paginateKeys = []string{
+	"a.txt",
+	"docs/",
+	"docs/a.txt",
+	"docs/b/c.txt",
+	"docs/d.txt",
+	"photos/1.png",
+	"photos/2.png",
+	"z.txt",
+}
+
+func paginateObjects() []ObjectInfo {
+	objects := make([]ObjectInfo, len(paginateKeys))
+	for i, key := range paginateKeys {
+		objects[i] = ObjectInfo{Key: key}
+	}
+	return objects
+}
+
+func objectKeys(objects []ObjectInfo) []string {
+	keys := []string{}
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	return keys
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     ListInput
+		objects   []string
+		prefixes  []string
+		truncated bool
+	}{
+		{
+			name:    "everything",
+			input:   ListInput{},
+			objects: paginateKeys,
+		},
+		{
+			name:    "prefix",
+			input:   ListInput{Prefix: "docs/"},
+			objects: []string{"docs/", "docs/a.txt", "docs/b/c.txt", "docs/d.txt"},
+		},
+		{
+			name:     "delimiter at the root",
+			input:    ListInput{Delimiter: "/"},
+			objects:  []string{"a.txt", "z.txt"},
+			prefixes: []string{"docs/", "photos/"},
+		},
+		{
+			name:     "delimiter in a folder",
+			input:    ListInput{Prefix: "docs/", Delimiter: "/"},
+			objects:  []string{"docs/", "docs/a.txt", "docs/d.txt"},
+			prefixes: []string{"docs/b/"},
+		},
+		{
+			name:     "prefix that is not a folder",
+			input:    ListInput{Prefix: "doc", Delimiter: "/"},
+			objects:  []string{},
+			prefixes: []string{"docs/"},
+		},
+		{
+			name:    "no match",
+			input:   ListInput{Prefix: "missing/", Delimiter: "/"},
+			objects: []string{},
+		},
+		{
+			name:      "common prefixes count against max keys",
+			input:     ListInput{Delimiter: "/", MaxKeys: 2},
+			objects:   []string{"a.txt"},
+			prefixes:  []string{"docs/"},
+			truncated: true,
+		},
+		{
+			name:     "max keys equal to the entries",
+			input:    ListInput{Delimiter: "/", MaxKeys: 4},
+			objects:  []string{"a.txt", "z.txt"},
+			prefixes: []string{"docs/", "photos/"},
+		},
+		{
+			name:    "max keys of zero uses the default",
+			input:   ListInput{MaxKeys: 0},
+			objects: paginateKeys,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := Paginate(paginateObjects(), test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if keys := objectKeys(out.Objects); !reflect.DeepEqual(keys, test.objects) {
+				t.Errorf("objects = %q, want %q", keys, test.objects)
+			}
+			if !reflect.DeepEqual(out.CommonPrefixes, test.prefixes) {
+				t.Errorf("common prefixes = %q, want %q", out.CommonPrefixes, test.prefixes)
+			}
+			if out.IsTruncated != test.truncated {
+				t.Errorf("truncated = %v, want %v", out.IsTruncated, test.truncated)
+			}
+			if out.IsTruncated == (out.NextContinuationToken == "") {
+				t.Errorf("continuation token %q does not match truncated = %v", out.NextContinuationToken, out.IsTruncated)
+			}
+		})
+	}
+}
+
+// TestPaginateContinuation follows the continuation tokens with every page
+// size and checks the pages add up to the unpaginated listing.
+func TestPaginateContinuation(t *testing.T) {
+	for _, input := range []ListInput{{}, {Delimiter: "/"}, {Prefix: "docs/", Delimiter: "/"}} {
+		full, err := Paginate(paginateObjects(), input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := append(objectKeys(full.Objects), full.CommonPrefixes...)
+		sort.Strings(want)
+
+		for maxKeys := 1; maxKeys <= len(want)+1; maxKeys++ {
+			var got []string
+			page := input
+			page.MaxKeys = maxKeys
+
+			for pages := 0; ; pages++ {
+				if pages > len(want) {
+					t.Fatalf("prefix %q, max keys %d: too many pages", input.Prefix, maxKeys)
+				}
+
+				out, err := Paginate(paginateObjects(), page)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if entries := len(out.Objects) + len(out.CommonPrefixes); entries > maxKeys {
+					t.Errorf("prefix %q, max keys %d: page has %d entries", input.Prefix, maxKeys, entries)
+				}
+
+				got = append(got, objectKeys(out.Objects)...)
+				got = append(got, out.CommonPrefixes...)
+
+				if !out.IsTruncated {
+					break
+				}
+				page.ContinuationToken = out.NextContinuationToken
+			}
+
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("prefix %q, max keys %d: pages hold %q, want %q", input.Prefix, maxKeys, got, want)
+			}
+		}
+	}
+}
+
+func TestPaginateInvalidToken(t *testing.T) {
+	for _, token := range []string{"not base64!", "="} {
+		_, err := Paginate(paginateObjects(), ListInput{ContinuationToken: token})
+		if !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("token %q: err = %v, want ErrInvalidToken", token, err)
+		}
+	}
+}
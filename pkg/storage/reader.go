@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ObjectReader gives seekable access to a stored object without downloading
+// it as a whole. Seeking is free; the next Read opens a ranged read from the
+// new position, up to the end of the object or of the range expected to start
+// there, so serving a byte range only transfers that range from the backend.
+type ObjectReader struct {
+	ctx     context.Context
+	backend Storage
+	key     string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+
+	// end is where the open read stops, ends the expected ranges by start
+	end  int64
+	ends map[int64]int64
+}
+
+// NewObjectReader creates an ObjectReader for the object described by info.
+func NewObjectReader(ctx context.Context, backend Storage, info *ObjectInfo) *ObjectReader {
+	return &ObjectReader{
+		ctx:     ctx,
+		backend: backend,
+		key:     info.Key,
+		size:    info.Size,
+	}
+}
+
+// ExpectRanges takes the Range header of the request being served, so reads
+// starting at one of its ranges only request that range. Reads beyond the
+// ranges still work, they take another request to the backend. Headers that
+// cannot be parsed are ignored.
+func (r *ObjectReader) ExpectRanges(header string) {
+	specs, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return
+	}
+
+	ends := make(map[int64]int64)
+	for _, spec := range strings.Split(specs, ",") {
+		first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+		if !ok {
+			return
+		}
+
+		var start, end int64
+		switch {
+		case first == "":
+			// a suffix range, the last bytes of the object
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return
+			}
+			start, end = r.size-n, r.size
+			if start < 0 {
+				start = 0
+			}
+		case last == "":
+			n, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || n < 0 {
+				return
+			}
+			start, end = n, r.size
+		default:
+			n, err := strconv.ParseInt(first, 10, 64)
+			m, err2 := strconv.ParseInt(last, 10, 64)
+			if err != nil || err2 != nil || n < 0 || m < n {
+				return
+			}
+			start, end = n, m+1
+		}
+
+		if end > r.size {
+			end = r.size
+		}
+
+		// overlapping ranges starting at the same byte read up to the
+		// furthest end
+		if end > ends[start] {
+			ends[start] = end
+		}
+	}
+
+	r.ends = ends
+}
+
+// Read reads from the current position, opening the object if needed.
+func (r *ObjectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		r.end = r.size
+		if end, ok := r.ends[r.offset]; ok && end > r.offset {
+			r.end = end
+		}
+
+		body, err := r.backend.GetRange(r.ctx, r.key, r.offset, r.end-r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+
+	if err == io.EOF && r.offset == r.end && r.offset < r.size {
+		// the expected range is done, reading on opens another one
+		r.body.Close()
+		r.body = nil
+		err = nil
+	} else if err == io.EOF && r.offset < r.size {
+		// the object may have been replaced by a shorter one in the meantime
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// Seek moves the position of the next Read. The open read, if any, is only
+// kept when the position does not change.
+func (r *ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("seek before start of object")
+	}
+
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+
+	r.offset = offset
+
+	return offset, nil
+}
+
+// Close releases the open read, if any.
+func (r *ObjectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+
+	err := r.body.Close()
+	r.body = nil
+
+	return err
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrBucketRequired is returned when no bucket was selected and no default
+// bucket is configured.
+var ErrBucketRequired = errors.New("bucket is required")
+
+// Registry holds a client for every bucket on the allowlist.
+type Registry struct {
+	clients       map[string]*Client
+	defaultBucket string
+}
+
+// NewRegistry creates an empty Registry. Requests that don't select a bucket
+// use defaultBucket, which may be empty to make the selection mandatory.
+func NewRegistry(defaultBucket string) *Registry {
+	return &Registry{
+		clients:       make(map[string]*Client),
+		defaultBucket: defaultBucket,
+	}
+}
+
+// Register adds a client to the allowlist under its name.
+func (r *Registry) Register(client *Client) {
+	r.clients[client.Name()] = client
+}
+
+// Client returns the client for the named bucket, or for the default bucket
+// when name is empty. Buckets that are not registered are rejected.
+func (r *Registry) Client(name string) (*Client, error) {
+	if name == "" {
+		name = r.defaultBucket
+	}
+
+	if name == "" {
+		return nil, ErrBucketRequired
+	}
+
+	client, found := r.clients[name]
+	if !found {
+		return nil, fmt.Errorf("bucket %q is not allowed", name)
+	}
+
+	return client, nil
+}
+
+// Names returns the names of all registered buckets in sorted order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.clients))
+	for name := range r.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DownloadRoute is the route under which the service serves the links
+// generated by LinkSigner.
+const DownloadRoute = "/files/"
+
+// LinkSigner generates and verifies download links for backends that have no
+// download URLs of their own and are served by the service instead. Backends
+// embed it to satisfy the Presign half of Storage and LinkVerifier.
+type LinkSigner struct {
+	bucket     string
+	publicURL  string
+	signingKey []byte
+}
+
+// NewLinkSigner creates a LinkSigner producing links to objects of the named
+// bucket below publicURL. When no secret is configured a random one is
+// generated, so links only stay valid for the lifetime of the process.
+func NewLinkSigner(bucket string, publicURL string, secret string) (*LinkSigner, error) {
+	signingKey := []byte(secret)
+	if len(signingKey) == 0 {
+		log.Println("URL_SIGNING_KEY is not set, generating a random key for download links")
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return &LinkSigner{
+		bucket:     bucket,
+		publicURL:  strings.TrimSuffix(publicURL, "/"),
+		signingKey: signingKey,
+	}, nil
+}
+
+// Presign returns a link to the service's own download route for key.
+func (l *LinkSigner) Presign(key string, expiry time.Duration) (string, error) {
+	expires := time.Now().Add(expiry).Unix()
+
+	query := url.Values{}
+	query.Set("bucket", l.bucket)
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", l.sign(key, expires))
+
+	return l.publicURL + DownloadRoute + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+// VerifyLink checks a signature produced by Presign.
+func (l *LinkSigner) VerifyLink(key string, expires int64, signature string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+
+	return hmac.Equal([]byte(l.sign(key, expires)), []byte(signature))
+}
+
+func (l *LinkSigner) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	mac.Write([]byte(l.bucket + "\n" + key + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned by backends when the requested key does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Storage is the set of object operations a backend has to provide. Keys are
+// flat, "/" separated paths; folders are represented by zero byte objects whose
+// key ends with a slash, the same way S3 does it.
+type Storage interface {
+	// Put stores the contents of body under key, replacing any existing object.
+	Put(ctx context.Context, key string, body io.Reader) error
+
+	// Get opens the object stored under key. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// GetRange opens length bytes of the object stored under key, starting at
+	// offset. A negative length reads up to the end of the object.
+	GetRange(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error)
+
+	// Stat returns the metadata of the object stored under key.
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+
+	// List returns one page of objects whose key starts with input.Prefix.
+	List(ctx context.Context, input ListInput) (*ListOutput, error)
+
+	// Delete removes the object stored under key.
+	Delete(ctx context.Context, key string) error
+
+	// Presign returns a URL that can be used to download the object without
+	// further authentication until expiry has passed.
+	Presign(key string, expiry time.Duration) (string, error)
+}
+
+// LinkVerifier is implemented by backends whose presigned links point back at
+// this service instead of at the storage provider. The service checks the
+// signature of such links before streaming the object.
+type LinkVerifier interface {
+	VerifyLink(key string, expires int64, signature string) bool
+}
+
+// CompletedPart identifies one uploaded part of a multipart upload.
+type CompletedPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// MultipartUploader is implemented by backends that can assemble an object
+// from separately uploaded parts, like S3 multipart uploads. Every part except
+// the last one has to be at least 5 MB.
+type MultipartUploader interface {
+	CreateMultipartUpload(ctx context.Context, key string) (string, error)
+	UploadPart(ctx context.Context, key string, uploadID string, number int, body io.ReadSeeker) (string, error)
+	CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []CompletedPart) error
+	AbortMultipartUpload(ctx context.Context, key string, uploadID string) error
+}
+
+// UploadPresigner is implemented by backends that let clients upload directly
+// to the storage provider with a presigned request.
+type UploadPresigner interface {
+	PresignUpload(key string, input PresignUploadInput) (*PresignedUpload, error)
+}
+
+// TagReader is implemented by backends that can attach key/value tags to
+// objects, like S3 object tagging.
+type TagReader interface {
+	GetTags(ctx context.Context, key string) (map[string]string, error)
+}
+
+// Copier is implemented by backends that can copy an object within the bucket
+// without downloading it, keeping its content type, metadata and tags unless
+// options say otherwise. Other backends are copied by reading the object and
+// writing it again.
+type Copier interface {
+	Copy(ctx context.Context, source string, destination string, options CopyOptions) error
+}
+
+// Observer is notified about the changes the service makes to a bucket, so
+// derived data like the search index can be kept up to date without listing
+// the bucket again. Changes made by other writers are not reported.
+type Observer interface {
+	ObjectStored(bucket string, info ObjectInfo)
+	ObjectRemoved(bucket string, key string)
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MaxTreeNodes caps the number of folders a single Tree call returns, so that
+// a large depth on a wide bucket cannot list the whole bucket.
+const MaxTreeNodes = 5000
+
+// treeWorkers is the number of folders listed concurrently by Tree.
+const treeWorkers = 8
+
+// FolderNode is a folder in the tree returned by Tree.
+type FolderNode struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	FolderCount int    `json:"folderCount"`
+	FileCount   int    `json:"fileCount"`
+
+	// Children is only filled for folders above the requested depth, a node
+	// with folders but without children has not been expanded
+	Children []*FolderNode `json:"children,omitempty"`
+}
+
+// FolderTree is the result of Tree. Truncated is set when expanding another
+// level would have gone over MaxTreeNodes, so it was left unexpanded.
+type FolderTree struct {
+	*FolderNode
+	Truncated bool `json:"truncated"`
+}
+
+// Tree returns the folder hierarchy below a folder down to depth levels, with
+// the number of child folders and files of every node. Each node is a single
+// delimited listing, so folders are taken from the common prefixes and folder
+// markers, and empty files are counted as files.
+func (s *Client) Tree(ctx context.Context, folderPath string, depth int) (*FolderTree, error) {
+	if (folderPath != "") && !strings.HasSuffix(folderPath, "/") {
+		folderPath += "/"
+	}
+
+	root := &FolderNode{Name: path.Base(folderPath), Path: folderPath}
+	if folderPath == "" {
+		root.Name = ""
+	}
+
+	tree := &FolderTree{FolderNode: root}
+	level := []*FolderNode{root}
+	listed := 1
+
+	// list the tree level by level, every node of a level concurrently; the
+	// nodes at the requested depth are only listed for their counts
+	for d := 0; ; d++ {
+		if err := s.listNodes(ctx, level, d < depth); err != nil {
+			return nil, err
+		}
+
+		var next []*FolderNode
+		for _, node := range level {
+			next = append(next, node.Children...)
+		}
+
+		if len(next) == 0 {
+			break
+		}
+
+		// rather than returning nodes without counts, leave the whole level
+		// unexpanded when it would go over the limit
+		if listed+len(next) > MaxTreeNodes {
+			for _, node := range level {
+				node.Children = nil
+			}
+			tree.Truncated = true
+			break
+		}
+
+		listed += len(next)
+		level = next
+	}
+
+	return tree, nil
+}
+
+// listNodes counts the children of every node and adds the child folders to
+// them when expand is set.
+func (s *Client) listNodes(ctx context.Context, nodes []*FolderNode, expand bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	work := make(chan *FolderNode)
+	errs := make(chan error, 1)
+	var wg sync.WaitGroup
+
+	for i := 0; i < treeWorkers && i < len(nodes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for node := range work {
+				if err := s.listNode(ctx, node, expand); err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+					cancel()
+				}
+			}
+		}()
+	}
+
+	for _, node := range nodes {
+		select {
+		case work <- node:
+		case <-ctx.Done():
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return ctx.Err()
+	}
+}
+
+func (s *Client) listNode(ctx context.Context, node *FolderNode, expand bool) error {
+	folders := make(map[string]struct{})
+	var children []*FolderNode
+
+	addFolder := func(prefix string) {
+		if _, found := folders[prefix]; found {
+			return
+		}
+		folders[prefix] = struct{}{}
+
+		if expand {
+			children = append(children, &FolderNode{
+				Name: path.Base(prefix),
+				Path: prefix,
+			})
+		}
+	}
+
+	token := ""
+	for {
+		resp, err := s.List(ctx, ListInput{
+			Prefix:            node.Path,
+			Delimiter:         "/",
+			ContinuationToken: token,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, prefix := range resp.CommonPrefixes {
+			addFolder(prefix)
+		}
+
+		for _, obj := range resp.Objects {
+			switch {
+			case obj.Key == node.Path:
+				continue // skip the folder itself
+			case strings.HasSuffix(obj.Key, "/"):
+				addFolder(obj.Key)
+			default:
+				node.FileCount++
+			}
+		}
+
+		if !resp.IsTruncated {
+			break
+		}
+		token = resp.NextContinuationToken
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Path < children[j].Path
+	})
+
+	node.FolderCount = len(folders)
+	node.Children = children
+
+	return nil
+}
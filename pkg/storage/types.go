@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"time"
+)
+
+type ObjectDetails struct {
+	Name         string    `json:"name"`
+	IsFolder     bool      `json:"isFolder"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+	DownloadLink string    `json:"downloadLink,omitempty"`
+}
+
+// CreateFolderRequest represents the request body structure for creating a folder
+type CreateFolderRequest struct {
+	FolderName string `json:"folderName"`
+}
+
+type ListFilesResponse struct {
+	Files               *[]ObjectDetails `json:"data"`
+	NextPageToken       string           `json:"nextPageToken,omitempty"` // for pagination purposes only if
+	IsLastPage          bool             `json:"isLastPage,omitempty"`    // for pagination purposes only if
+	NoOfRecordsReturned int32            `json:"noOfRecordsReturned,omitempty"`
+	FilesCount          int32            `json:"filesCount,omitempty"`
+	FoldersCount        int32            `json:"foldersCount,omitempty"`
+	TotalCount          *int             `json:"totalCount,omitempty"` // only when known without extra requests
+}
+
+type SuccessResponse struct {
+	Status       string      `json:"status"`
+	ResponseCode int         `json:"response_code"`
+	Data         interface{} `json:"data"`
+}
+
+type FailureResponse struct {
+	Status       string `json:"status"`
+	ResponseCode int    `json:"response_code"`
+	ErrorMessage string `json:"error_message"`
+}
+
+type UploadPayload struct {
+	Bucket     string `json:"bucket"`
+	FolderPath string `json:"folderPath"`
+}
+
+// FileInfo represents the details of a file or folder
+type FileInfo struct {
+	Name     string `json:"name"`
+	IsFolder bool   `json:"isFolder"`
+	// Add more fields as per your requirements
+}
+
+type FilterOptions struct {
+	SizeRange          string
+	TimeRange          string
+	FileTypes          []string
+	FilenameQuery      string
+	FilenameFilterType string
+	FileSize           int64
+	FileSizeFilterType string
+
+	// ModifiedFrom (inclusive) and ModifiedTo (exclusive) bound the last
+	// modified time, zero values leave that side open. They are combined with
+	// TimeRange, which is evaluated in Location (UTC when nil).
+	ModifiedFrom time.Time
+	ModifiedTo   time.Time
+	Location     *time.Location
+
+	// MinSize and MaxSize are inclusive size bounds in bytes, nil when unset
+	MinSize *int64
+	MaxSize *int64
+
+	// Expression is an additional filter, such as a parsed filter expression
+	Expression Matcher
+}
+
+// Matcher decides whether an object belongs in a listing.
+type Matcher interface {
+	Match(object ObjectDetails) bool
+}
+
+type FilterSizeRange struct {
+	MinSize int64
+	MaxSize int64
+}
+
+// ObjectInfo describes a single object as reported by a storage backend.
+// Backends fill in what they know; only S3 reports the fields after
+// ContentType, and Tags are only set where explicitly fetched.
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+	ETag         string    `json:"etag,omitempty"`
+	ContentType  string    `json:"contentType,omitempty"`
+
+	StorageClass         string            `json:"storageClass,omitempty"`
+	ServerSideEncryption string            `json:"serverSideEncryption,omitempty"`
+	SSEKMSKeyID          string            `json:"sseKmsKeyId,omitempty"`
+	VersionID            string            `json:"versionId,omitempty"`
+	Metadata             map[string]string `json:"metadata,omitempty"`
+	Tags                 map[string]string `json:"tags,omitempty"`
+
+	CacheControl       string `json:"cacheControl,omitempty"`
+	ContentEncoding    string `json:"contentEncoding,omitempty"`
+	ContentDisposition string `json:"contentDisposition,omitempty"`
+	ContentLanguage    string `json:"contentLanguage,omitempty"`
+	Expires            string `json:"expires,omitempty"`
+}
+
+// ListInput mirrors the subset of ListObjectsV2 parameters used by the service.
+type ListInput struct {
+	Prefix            string
+	Delimiter         string
+	ContinuationToken string
+	MaxKeys           int
+}
+
+// ListOutput is a single page of a listing. CommonPrefixes is only populated
+// when a delimiter was supplied.
+type ListOutput struct {
+	Objects               []ObjectInfo
+	CommonPrefixes        []string
+	NextContinuationToken string
+	IsTruncated           bool
+}
+
+// PresignUploadInput holds the constraints baked into a presigned upload.
+// Zero values leave the corresponding property unconstrained.
+type PresignUploadInput struct {
+	ContentType string
+	MaxSize     int64
+	Size        int64 // exact size, only enforceable for PUT uploads
+	Expiry      time.Duration
+}
+
+// PresignedUpload describes how a client can upload one object directly to the
+// storage provider, either with a single PUT or with a browser form POST. URL
+// and Method are empty when only the POST policy can enforce the limits.
+type PresignedUpload struct {
+	Key     string            `json:"key"`
+	URL     string            `json:"url,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Post    *PresignedPost    `json:"post,omitempty"`
+	Expires time.Time         `json:"expires"`
+}
+
+// PresignedPost is an HTML form upload: all fields have to be sent as form
+// fields, followed by the file itself in a last field named "file".
+type PresignedPost struct {
+	URL    string            `json:"url"`
+	Fields map[string]string `json:"fields"`
+}
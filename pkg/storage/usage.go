@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UsageStats sums up the size of a group of files.
+type UsageStats struct {
+	Bytes   int64 `json:"bytes"`
+	Objects int64 `json:"objects"`
+}
+
+// FolderUsage summarizes the storage used below a folder.
+type FolderUsage struct {
+	Path        string `json:"path"`
+	TotalBytes  int64  `json:"totalBytes"`
+	ObjectCount int64  `json:"objectCount"`
+	FolderCount int64  `json:"folderCount"`
+
+	// LargestFiles are the biggest files anywhere below the folder
+	LargestFiles []ObjectDetails `json:"largestFiles"`
+
+	// ByExtension groups the files by lower case extension, "" for files
+	// without one
+	ByExtension map[string]*UsageStats `json:"byExtension"`
+
+	// ByFolder groups the files by the immediate child folder they are in,
+	// files directly in the folder are counted in Files
+	ByFolder map[string]*UsageStats `json:"byFolder"`
+	Files    UsageStats             `json:"files"`
+
+	ComputedAt time.Time `json:"computedAt"`
+}
+
+// Usage walks all objects below a folder and sums up their sizes. Folders are
+// counted whether they have a marker object or only exist as a prefix.
+func (s *Client) Usage(ctx context.Context, folderPath string, largest int) (*FolderUsage, error) {
+	if (folderPath != "") && !strings.HasSuffix(folderPath, "/") {
+		folderPath += "/"
+	}
+
+	usage := &FolderUsage{
+		Path:         folderPath,
+		LargestFiles: []ObjectDetails{},
+		ByExtension:  make(map[string]*UsageStats),
+		ByFolder:     make(map[string]*UsageStats),
+	}
+
+	folders := make(map[string]struct{})
+
+	err := s.Walk(ctx, folderPath, WalkOptions{}, func(object ObjectDetails) error {
+		name := strings.TrimPrefix(object.Name, folderPath)
+
+		// every prefix of the key below the folder is a folder
+		for i := 0; i < len(name); i++ {
+			if name[i] == '/' {
+				folders[name[:i+1]] = struct{}{}
+			}
+		}
+
+		if object.IsFolder {
+			// list empty child folders in the breakdown as well
+			if i := strings.IndexByte(name, '/'); i >= 0 && i == len(name)-1 {
+				if _, found := usage.ByFolder[folderPath+name]; !found {
+					usage.ByFolder[folderPath+name] = &UsageStats{}
+				}
+			}
+
+			return nil
+		}
+
+		usage.TotalBytes += object.Size
+		usage.ObjectCount++
+
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+		add(usage.ByExtension, ext, object.Size)
+
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			add(usage.ByFolder, folderPath+name[:i+1], object.Size)
+		} else {
+			usage.Files.Bytes += object.Size
+			usage.Files.Objects++
+		}
+
+		usage.LargestFiles = keepLargest(usage.LargestFiles, object, largest)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	usage.FolderCount = int64(len(folders))
+	usage.ComputedAt = time.Now().UTC()
+
+	return usage, nil
+}
+
+func add(groups map[string]*UsageStats, group string, size int64) {
+	stats, found := groups[group]
+	if !found {
+		stats = &UsageStats{}
+		groups[group] = stats
+	}
+
+	stats.Bytes += size
+	stats.Objects++
+}
+
+// keepLargest inserts object into files, which is sorted by descending size,
+// and keeps at most n entries.
+func keepLargest(files []ObjectDetails, object ObjectDetails, n int) []ObjectDetails {
+	if n <= 0 || (len(files) == n && object.Size <= files[n-1].Size) {
+		return files
+	}
+
+	i := sort.Search(len(files), func(i int) bool {
+		return files[i].Size < object.Size
+	})
+
+	files = append(files, ObjectDetails{})
+	copy(files[i+1:], files[i:])
+	files[i] = object
+
+	if len(files) > n {
+		files = files[:n]
+	}
+
+	return files
+}
@@ -0,0 +1,360 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func GetFailureResponse(err error) FailureResponse {
+	return FailureResponse{
+		Status:       "Failure",
+		ResponseCode: http.StatusInternalServerError,
+		ErrorMessage: err.Error(),
+	}
+}
+
+func GetListFolderSuccessResponse(payload *ListFilesResponse) SuccessResponse {
+	return SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         payload,
+	}
+}
+
+func GetSuccessResponse(message string) SuccessResponse {
+	return SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         message,
+	}
+}
+
+// custom function to sort the files by name, last modified, type or size.
+// Entries that compare equal are ordered by name, so the order is the same on
+// every request and can be paginated.
+func SortFiles(files []ObjectDetails, c echo.Context) *[]ObjectDetails {
+	return SortFilesBy(files, c.QueryParam("sortBy"), c.QueryParam("order"))
+}
+
+// SortFilesBy sorts the files like SortFiles, with the sort key and order
+// given directly instead of taken from the request.
+func SortFilesBy(files []ObjectDetails, sortBy string, order string) *[]ObjectDetails {
+	if sortBy == "" {
+		sortBy = "name"
+	}
+
+	// compare returns a negative number when a sorts before b in ascending order
+	var compare func(a, b *ObjectDetails) int
+
+	switch sortBy {
+	case "date":
+		compare = func(a, b *ObjectDetails) int {
+			return a.LastModified.Compare(b.LastModified)
+		}
+	case "type":
+		// folders first, then files grouped by extension
+		compare = func(a, b *ObjectDetails) int {
+			if a.IsFolder != b.IsFolder {
+				if a.IsFolder {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(strings.ToLower(path.Ext(a.Name)), strings.ToLower(path.Ext(b.Name)))
+		}
+	case "size":
+		compare = func(a, b *ObjectDetails) int {
+			switch {
+			case a.Size < b.Size:
+				return -1
+			case a.Size > b.Size:
+				return 1
+			}
+			return 0
+		}
+	default:
+		compare = func(a, b *ObjectDetails) int {
+			return 0
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		result := compare(&files[i], &files[j])
+		if result == 0 {
+			result = strings.Compare(files[i].Name, files[j].Name)
+		}
+
+		if order == "asc" {
+			return result < 0
+		}
+		return result > 0
+	})
+
+	return &files
+}
+
+// custom function to filter the files by size range, date range, file type, filename and file size
+func FilterFiles(files []ObjectDetails, options FilterOptions) *[]ObjectDetails {
+	var filteredFiles []ObjectDetails
+	filterFilesBySizeRange := func(sizeRange string, files []ObjectDetails) *[]ObjectDetails {
+		rangeValues, found := sizeRanges[sizeRange]
+		var filesInRange []ObjectDetails
+		if !found {
+			return &filesInRange // Invalid size range
+		}
+
+		for _, file := range files {
+			if file.Size >= rangeValues.MinSize && (rangeValues.MaxSize == -1 || file.Size <= rangeValues.MaxSize) {
+				filesInRange = append(filesInRange, file)
+			}
+		}
+		return &filesInRange
+	}
+
+	filterFilesByTime := func(from, to time.Time, files []ObjectDetails) *[]ObjectDetails {
+		var filesInRange []ObjectDetails
+		for _, file := range files {
+			if (from.IsZero() || !file.LastModified.Before(from)) && (to.IsZero() || file.LastModified.Before(to)) {
+				filesInRange = append(filesInRange, file)
+			}
+		}
+
+		return &filesInRange
+	}
+
+	filterFilesByBounds := func(minSize, maxSize *int64, files []ObjectDetails) *[]ObjectDetails {
+		var filesInRange []ObjectDetails
+		for _, file := range files {
+			if (minSize == nil || file.Size >= *minSize) && (maxSize == nil || file.Size <= *maxSize) {
+				filesInRange = append(filesInRange, file)
+			}
+		}
+
+		return &filesInRange
+	}
+
+	filterFilesByTypes := func(fileTypes []string, files []ObjectDetails) *[]ObjectDetails {
+		var filesInRange []ObjectDetails
+		for _, file := range files {
+			// get the file extension by splitting the file name and picking the last element
+			ext := strings.Split(file.Name, ".")[len(strings.Split(file.Name, "."))-1]
+
+			// check if the file extension is present in the fileTypes array
+			for _, fileType := range fileTypes {
+				if fileType == ext {
+					filesInRange = append(filesInRange, file)
+				}
+			}
+		}
+
+		return &filesInRange
+	}
+
+	filterFilesByFilename := func(query, filterType string, files []ObjectDetails) *[]ObjectDetails {
+		var filesInRange []ObjectDetails
+
+		for _, file := range files {
+			filename := strings.ToLower(file.Name)
+
+			switch filterType {
+			case "contains":
+				if strings.Contains(filename, strings.ToLower(query)) {
+					filesInRange = append(filesInRange, file)
+				}
+			case "startsWith":
+				if strings.HasPrefix(filename, strings.ToLower(query)) {
+					filesInRange = append(filesInRange, file)
+				}
+			case "endsWith":
+				if strings.HasSuffix(filename, strings.ToLower(query)) {
+					filesInRange = append(filesInRange, file)
+				}
+			}
+		}
+
+		return &filesInRange
+	}
+
+	filterFilesByFileSize := func(fileSize int64, filterType string, files []ObjectDetails) *[]ObjectDetails {
+		var filesInRange []ObjectDetails
+
+		for _, file := range files {
+			switch filterType {
+			case "gt":
+				if file.Size > fileSize {
+					filesInRange = append(filesInRange, file)
+				}
+			case "gte":
+				if file.Size >= fileSize {
+					filesInRange = append(filesInRange, file)
+				}
+			case "lt":
+				if file.Size < fileSize {
+					filesInRange = append(filesInRange, file)
+				}
+			case "lte":
+				if file.Size <= fileSize {
+					filesInRange = append(filesInRange, file)
+				}
+			case "eq":
+				if file.Size == fileSize {
+					filesInRange = append(filesInRange, file)
+				}
+			}
+		}
+
+		return &filesInRange
+	}
+
+	// Filter by size range
+	if options.SizeRange != "" {
+		filteredFiles = *filterFilesBySizeRange(options.SizeRange, files)
+	} else {
+		filteredFiles = files
+	}
+
+	// Filter by size bounds
+	if options.MinSize != nil || options.MaxSize != nil {
+		filteredFiles = *filterFilesByBounds(options.MinSize, options.MaxSize, filteredFiles)
+	}
+
+	// Filter by date range
+	if from, to, found := options.TimeBounds(time.Now()); !found {
+		filteredFiles = nil // Invalid date range
+	} else if !from.IsZero() || !to.IsZero() {
+		filteredFiles = *filterFilesByTime(from, to, filteredFiles)
+	}
+
+	// Filter by file type
+	if options.FileTypes != nil {
+		filteredFiles = *filterFilesByTypes(options.FileTypes, filteredFiles)
+	}
+
+	// Filter by filename
+	if options.FilenameQuery != "" && options.FilenameFilterType != "" {
+		filteredFiles = *filterFilesByFilename(options.FilenameQuery, options.FilenameFilterType, filteredFiles)
+	}
+
+	// Filter by file size
+	if options.FileSizeFilterType != "" {
+		filteredFiles = *filterFilesByFileSize(options.FileSize, options.FileSizeFilterType, filteredFiles)
+	}
+
+	// Filter by expression
+	if options.Expression != nil {
+		var matching []ObjectDetails
+		for _, file := range filteredFiles {
+			if options.Expression.Match(file) {
+				matching = append(matching, file)
+			}
+		}
+		filteredFiles = matching
+	}
+
+	return &filteredFiles
+}
+
+// Validate reports filter options FilterFiles does not know about.
+func (options FilterOptions) Validate() error {
+	if _, found := sizeRanges[options.SizeRange]; options.SizeRange != "" && !found {
+		return fmt.Errorf("invalid size range %q", options.SizeRange)
+	}
+
+	if _, found := timeRanges[options.TimeRange]; options.TimeRange != "" && !found {
+		return fmt.Errorf("invalid time range %q", options.TimeRange)
+	}
+
+	if options.TimeRange == "custom" && options.ModifiedFrom.IsZero() && options.ModifiedTo.IsZero() {
+		return errors.New("a custom time range needs from or to")
+	}
+
+	if !options.ModifiedFrom.IsZero() && !options.ModifiedTo.IsZero() && !options.ModifiedFrom.Before(options.ModifiedTo) {
+		return errors.New("from must be before to")
+	}
+
+	if options.MinSize != nil && options.MaxSize != nil && *options.MinSize > *options.MaxSize {
+		return errors.New("minSize must not be larger than maxSize")
+	}
+
+	switch options.FilenameFilterType {
+	case "", "contains", "startsWith", "endsWith":
+	default:
+		return fmt.Errorf("invalid filename filter type %q", options.FilenameFilterType)
+	}
+
+	switch options.FileSizeFilterType {
+	case "", "gt", "gte", "lt", "lte", "eq":
+	default:
+		return fmt.Errorf("invalid file size filter type %q", options.FileSizeFilterType)
+	}
+
+	return nil
+}
+
+// TimeBounds combines TimeRange, evaluated at now in the options' time zone,
+// with ModifiedFrom and ModifiedTo into a single [from, to) interval. Zero
+// bounds are open. found is false for an unknown TimeRange.
+func (options FilterOptions) TimeBounds(now time.Time) (from, to time.Time, found bool) {
+	from, to = options.ModifiedFrom, options.ModifiedTo
+
+	if options.TimeRange == "" {
+		return from, to, true
+	}
+
+	bounds, found := timeRanges[options.TimeRange]
+	if !found {
+		return from, to, false
+	}
+
+	location := options.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	rangeFrom, rangeTo := bounds(now.In(location))
+
+	// both bounds have to hold, so keep the narrower one on each side
+	if rangeFrom.After(from) {
+		from = rangeFrom
+	}
+	if !rangeTo.IsZero() && (to.IsZero() || rangeTo.Before(to)) {
+		to = rangeTo
+	}
+
+	return from, to, true
+}
+
+// ParseTimestamp parses an ISO 8601 timestamp or date. Values without a UTC
+// offset are in location. A date means its midnight, or the following
+// midnight when end is set, so that an end date includes the whole day.
+func ParseTimestamp(value string, location *time.Location, end bool) (time.Time, error) {
+	if location == nil {
+		location = time.UTC
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range []string{"2006-01-02T15:04:05.999999999", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+
+	if t, err := time.ParseInLocation(time.DateOnly, value, location); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid timestamp %q, expected an ISO 8601 date or date and time", value)
+}
@@ -0,0 +1,543 @@
+// Package trash implements soft deletes. Deleted files and folders are moved
+// into a trash folder of their bucket, hidden from the listings, from where
+// they can be restored until they are purged after the retention period.
+package trash
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"file-management-service/config"
+	"file-management-service/pkg/cache"
+	"file-management-service/pkg/storage"
+)
+
+// ErrEntryNotFound is returned for unknown trash entries.
+var ErrEntryNotFound = errors.New("trash entry not found")
+
+// validID matches the IDs of trash entries: the deletion time in nanoseconds
+// as 16 hex digits, so IDs sort by age, followed by 16 random hex digits.
+var validID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// purgeInterval is how often expired entries are purged.
+const purgeInterval = time.Hour
+
+// moveWorkers bounds the objects moved concurrently.
+const moveWorkers = 8
+
+// maxFailures bounds the failures kept on a Result; Failed is always exact.
+const maxFailures = 100
+
+// Entry is a deleted file or folder. Its objects are kept below the entry's
+// folder in the trash under their original keys, and the entry itself is
+// stored next to that folder as JSON.
+type Entry struct {
+	ID        string    `json:"id"`
+	Path      string    `json:"path"`
+	IsFolder  bool      `json:"isFolder"`
+	Objects   int64     `json:"objects"`
+	Size      int64     `json:"size"`
+	DeletedAt time.Time `json:"deletedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Result is the outcome of moving the objects of an entry into or out of
+// the trash.
+type Result struct {
+	Entry    *Entry    `json:"entry"`
+	Moved    int64     `json:"moved"`
+	Failed   int64     `json:"failed"`
+	Failures []Failure `json:"failures,omitempty"`
+
+	// size is the number of bytes moved
+	size int64
+}
+
+// Failure is an object that could not be moved.
+type Failure struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// Trash keeps the trash of every bucket of a Registry.
+type Trash struct {
+	prefix    string
+	retention time.Duration
+	buckets   *storage.Registry
+	cache     *cache.URLCache
+	wg        sync.WaitGroup
+}
+
+// New creates the Trash and hides the trash folder, config.TrashPrefix, from
+// the listings of all buckets of the registry.
+func New(config *config.Config, buckets *storage.Registry, cache *cache.URLCache) *Trash {
+	for _, name := range buckets.Names() {
+		client, err := buckets.Client(name)
+		if err == nil {
+			client.Hide(config.TrashPrefix)
+		}
+	}
+
+	return &Trash{
+		prefix:    config.TrashPrefix,
+		retention: time.Duration(config.TrashRetentionDays) * 24 * time.Hour,
+		buckets:   buckets,
+		cache:     cache,
+	}
+}
+
+// Start purges the expired entries of all buckets now and then every
+// purgeInterval, until ctx is cancelled.
+func (t *Trash) Start(ctx context.Context) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			t.purge(ctx)
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close waits for a running purge to stop. Cancel the context passed to
+// Start first.
+func (t *Trash) Close() {
+	t.wg.Wait()
+}
+
+// Delete moves a file into the trash. A folder marker is moved on its own,
+// the objects below it stay in place; DeleteFolder moves whole folders.
+func (t *Trash) Delete(ctx context.Context, client *storage.Client, key string) (*Result, error) {
+	if key == "" {
+		return nil, errors.New("path is required")
+	}
+	if strings.HasPrefix(key, t.prefix) {
+		return nil, fmt.Errorf("%s is in the trash already", key)
+	}
+
+	info, err := client.Backend().Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(key, "/") {
+		return t.deleteMarker(ctx, client, key)
+	}
+
+	entry, err := t.newEntry(key, false)
+	if err != nil {
+		return nil, err
+	}
+	entry.Objects = 1
+	entry.Size = info.Size
+
+	// the entry is saved first, so whatever ends up in the trash is purged
+	if err := t.save(ctx, client, entry); err != nil {
+		return nil, err
+	}
+
+	// a copy that was made is kept with its entry, so it is purged later
+	if moved, err := client.MoveObject(ctx, *info, t.dir(entry.ID)+key, storage.ConflictOverwrite, t.cache); err != nil {
+		if moved == "" {
+			t.discard(ctx, client, entry.ID)
+		}
+		return nil, err
+	}
+
+	return &Result{Entry: entry, Moved: 1}, nil
+}
+
+// deleteMarker moves a folder marker into the trash. Markers have no content
+// to copy, so the marker is created in the trash and deleted in place.
+func (t *Trash) deleteMarker(ctx context.Context, client *storage.Client, key string) (*Result, error) {
+	entry, err := t.newEntry(key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.save(ctx, client, entry); err != nil {
+		return nil, err
+	}
+
+	if err := client.CreateFolder(ctx, t.dir(entry.ID)+key); err != nil {
+		t.discard(ctx, client, entry.ID)
+		return nil, err
+	}
+
+	if err := client.DeleteObject(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return &Result{Entry: entry, Moved: 1}, nil
+}
+
+// DeleteFolder moves a folder with everything below it into the trash.
+// Objects that cannot be moved stay in place and are reported as failures.
+func (t *Trash) DeleteFolder(ctx context.Context, client *storage.Client, folder string) (*Result, error) {
+	folder = strings.TrimPrefix(folder, "/")
+	if folder == "" {
+		return nil, errors.New("the root of the bucket cannot be moved to the trash")
+	}
+	if !strings.HasSuffix(folder, "/") {
+		folder += "/"
+	}
+	if strings.HasPrefix(folder, t.prefix) {
+		return nil, fmt.Errorf("%s is in the trash already", folder)
+	}
+
+	// the folder has to exist, as a marker or through the objects below it
+	resp, err := client.List(ctx, storage.ListInput{Prefix: folder, MaxKeys: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Objects) == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	entry, err := t.newEntry(folder, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.save(ctx, client, entry); err != nil {
+		return nil, err
+	}
+
+	// the entry is kept even when nothing could be moved, failed moves may
+	// have left copies in the trash
+	result, err := t.moveTree(ctx, client, folder, t.dir(entry.ID)+folder, storage.ConflictOverwrite)
+	result.Entry = entry
+
+	entry.Objects = result.Moved
+	entry.Size = result.size
+	if err := t.save(ctx, client, entry); err != nil {
+		return result, err
+	}
+
+	return result, err
+}
+
+// List returns the entries in the trash of a bucket, the most recently
+// deleted first.
+func (t *Trash) List(ctx context.Context, client *storage.Client) ([]*Entry, error) {
+	ids, err := t.ids(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, 0, len(ids))
+	for _, id := range ids {
+		entry, err := t.Get(ctx, client, id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+
+	return entries, nil
+}
+
+// Get returns an entry of the trash of a bucket.
+func (t *Trash) Get(ctx context.Context, client *storage.Client, id string) (*Entry, error) {
+	if !validID.MatchString(id) {
+		return nil, ErrEntryNotFound
+	}
+
+	body, err := client.GetFile(ctx, t.manifest(id))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	entry := &Entry{}
+	if err := json.NewDecoder(body).Decode(entry); err != nil {
+		return nil, err
+	}
+	entry.ExpiresAt = entry.DeletedAt.Add(t.retention)
+
+	return entry, nil
+}
+
+// Restore moves the objects of an entry back to their original keys, with
+// policy applied to the keys that were taken in the meantime. The entry is
+// removed from the trash once all of its objects were restored.
+func (t *Trash) Restore(ctx context.Context, client *storage.Client, id string, policy storage.ConflictPolicy) (*Result, error) {
+	entry, err := t.Get(ctx, client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := t.moveTree(ctx, client, t.dir(id), "", policy)
+	result.Entry = entry
+
+	if err != nil || result.Failed > 0 {
+		return result, err
+	}
+
+	_, err = t.Remove(ctx, client, id)
+	return result, err
+}
+
+// Remove deletes an entry and its objects for good.
+func (t *Trash) Remove(ctx context.Context, client *storage.Client, id string) (*storage.DeleteResult, error) {
+	if _, err := t.Get(ctx, client, id); err != nil {
+		return nil, err
+	}
+
+	result, err := client.BulkDelete(ctx, nil, []string{t.dir(id)})
+	if err != nil || result.Failed > 0 {
+		return result, err
+	}
+
+	// the entry goes last, so objects that could not be deleted are still
+	// purged later
+	if err := client.DeleteObject(ctx, t.manifest(id)); err != nil {
+		return result, err
+	}
+	result.Deleted++
+
+	return result, nil
+}
+
+// Empty deletes everything in the trash of a bucket for good.
+func (t *Trash) Empty(ctx context.Context, client *storage.Client) (*storage.DeleteResult, error) {
+	return client.BulkDelete(ctx, nil, []string{t.prefix})
+}
+
+// purge removes the expired entries of all buckets.
+func (t *Trash) purge(ctx context.Context) {
+	expired := time.Now().Add(-t.retention)
+
+	for _, name := range t.buckets.Names() {
+		client, err := t.buckets.Client(name)
+		if err != nil {
+			continue
+		}
+
+		ids, err := t.ids(ctx, client)
+		if err != nil {
+			log.Printf("Failed to list the trash of bucket %s: %s", name, err)
+			continue
+		}
+
+		for _, id := range ids {
+			if deletedAt(id).After(expired) {
+				break // the IDs are sorted by age
+			}
+
+			if _, err := t.Remove(ctx, client, id); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to purge trash entry %s of bucket %s: %s", id, name, err)
+			}
+		}
+	}
+}
+
+// ids lists the IDs of the entries in the trash of a bucket, oldest first.
+func (t *Trash) ids(ctx context.Context, client *storage.Client) ([]string, error) {
+	var ids []string
+	token := ""
+
+	for {
+		resp, err := client.List(ctx, storage.ListInput{
+			Prefix:            t.prefix,
+			Delimiter:         "/",
+			ContinuationToken: token,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range resp.Objects {
+			id := strings.TrimSuffix(strings.TrimPrefix(object.Key, t.prefix), ".json")
+			if validID.MatchString(id) {
+				ids = append(ids, id)
+			}
+		}
+
+		token = resp.NextContinuationToken
+		if !resp.IsTruncated || token == "" {
+			break
+		}
+	}
+
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// moveTree moves every object below the folder from to the same key below
+// the folder to. Files are moved concurrently; folder markers are moved last,
+// and only when every file was moved, so a failed move keeps its folders.
+func (t *Trash) moveTree(ctx context.Context, client *storage.Client, from string, to string, policy storage.ConflictPolicy) (*Result, error) {
+	result := &Result{}
+	var mutex sync.Mutex
+
+	work := make(chan storage.ObjectDetails)
+	var wg sync.WaitGroup
+
+	for i := 0; i < moveWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for object := range work {
+				size, err := t.moveObject(ctx, client, object.Name, to+strings.TrimPrefix(object.Name, from), policy)
+
+				mutex.Lock()
+				if err != nil {
+					result.Failed++
+					if len(result.Failures) < maxFailures {
+						result.Failures = append(result.Failures, Failure{Key: object.Name, Error: err.Error()})
+					}
+				} else {
+					result.Moved++
+					result.size += size
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	var markers []string
+	err := client.Walk(ctx, from, storage.WalkOptions{}, func(object storage.ObjectDetails) error {
+		if object.IsFolder {
+			markers = append(markers, object.Name)
+			return nil
+		}
+
+		select {
+		case work <- object:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	close(work)
+	wg.Wait()
+
+	if err != nil || result.Failed > 0 {
+		return result, err
+	}
+
+	// the folder itself is not part of the walk
+	if _, err := client.Backend().Stat(ctx, from); err == nil {
+		markers = append(markers, from)
+	}
+
+	// nested folders go before their parents, which matters for backends
+	// where folders are real directories
+	sort.Sort(sort.Reverse(sort.StringSlice(markers)))
+
+	for _, marker := range markers {
+		destination := to + strings.TrimPrefix(marker, from)
+		if destination != "" {
+			if err := client.CreateFolder(ctx, destination); err != nil {
+				return result, err
+			}
+		}
+
+		if err := client.DeleteObject(ctx, marker); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// moveObject moves a single file and returns its size.
+func (t *Trash) moveObject(ctx context.Context, client *storage.Client, key string, destination string, policy storage.ConflictPolicy) (int64, error) {
+	// the listing has no ETag, which the copy is verified against
+	info, err := client.Backend().Stat(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := client.MoveObject(ctx, *info, destination, policy, t.cache); err != nil {
+		return 0, err
+	}
+
+	return info.Size, nil
+}
+
+// newEntry creates an entry for a file or folder deleted now.
+func (t *Trash) newEntry(path string, isFolder bool) (*Entry, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	return &Entry{
+		ID:        fmt.Sprintf("%016x%s", now.UnixNano(), hex.EncodeToString(random)),
+		Path:      path,
+		IsFolder:  isFolder,
+		DeletedAt: now,
+		ExpiresAt: now.Add(t.retention),
+	}, nil
+}
+
+// save writes an entry to the trash.
+func (t *Trash) save(ctx context.Context, client *storage.Client, entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return client.UploadFile(ctx, bytes.NewReader(data), t.manifest(entry.ID))
+}
+
+// discard removes an entry that ended up without objects.
+func (t *Trash) discard(ctx context.Context, client *storage.Client, id string) {
+	if err := client.DeleteObject(ctx, t.manifest(id)); err != nil {
+		log.Printf("Failed to remove trash entry %s of bucket %s: %s", id, client.Name(), err)
+	}
+}
+
+// dir returns the folder holding the objects of an entry.
+func (t *Trash) dir(id string) string {
+	return t.prefix + id + "/"
+}
+
+// manifest returns the key of an entry.
+func (t *Trash) manifest(id string) string {
+	return t.prefix + id + ".json"
+}
+
+// deletedAt returns the time encoded in an entry ID.
+func deletedAt(id string) time.Time {
+	nanos, err := strconv.ParseInt(id[:16], 16, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanos)
+}
@@ -0,0 +1,288 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"file-management-service/config"
+	"file-management-service/pkg/cache"
+	"file-management-service/pkg/memory"
+	"file-management-service/pkg/storage"
+)
+
+// newTestTrash returns the trash of an in-memory bucket holding keys, each
+// with its key as content.
+func newTestTrash(t *testing.T, keys ...string) (*Trash, *storage.Client) {
+	t.Helper()
+
+	appConfig := &config.Config{
+		Name:               "test",
+		PublicURL:          "http://localhost",
+		URLSigningKey:      "secret",
+		TrashPrefix:        ".trash/",
+		TrashRetentionDays: 30,
+	}
+
+	backend, err := memory.NewClient(appConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := storage.NewClient("test", backend)
+	for _, key := range keys {
+		if err := client.UploadFile(context.Background(), strings.NewReader(key), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buckets := storage.NewRegistry("test")
+	buckets.Register(client)
+
+	return New(appConfig, buckets, cache.NewURLCache()), client
+}
+
+// visible lists the keys of the bucket outside of the trash.
+func visible(t *testing.T, client *storage.Client) []string {
+	t.Helper()
+
+	resp, err := client.List(context.Background(), storage.ListInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{}
+	for _, object := range resp.Objects {
+		keys = append(keys, object.Key)
+	}
+	return keys
+}
+
+// stored lists every key of the bucket, the trash included.
+func stored(t *testing.T, client *storage.Client) []string {
+	t.Helper()
+
+	resp, err := client.Backend().List(context.Background(), storage.ListInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{}
+	for _, object := range resp.Objects {
+		keys = append(keys, object.Key)
+	}
+	return keys
+}
+
+func read(t *testing.T, client *storage.Client, key string) string {
+	t.Helper()
+
+	body, err := client.GetFile(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDeleteAndRestoreFile(t *testing.T) {
+	ctx := context.Background()
+	trash, client := newTestTrash(t, "docs/a.txt", "docs/b.txt")
+
+	result, err := trash.Delete(ctx, client, "docs/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Moved != 1 || result.Entry.Path != "docs/a.txt" || result.Entry.IsFolder {
+		t.Errorf("result = %+v, entry = %+v", result, result.Entry)
+	}
+
+	if got := visible(t, client); !reflect.DeepEqual(got, []string{"docs/b.txt"}) {
+		t.Errorf("visible = %q, want the trash hidden", got)
+	}
+
+	// the manifest and the object are stored below the prefix
+	id := result.Entry.ID
+	want := []string{".trash/" + id + ".json", ".trash/" + id + "/docs/a.txt", "docs/b.txt"}
+	if got := stored(t, client); !reflect.DeepEqual(got, want) {
+		t.Errorf("stored = %q, want %q", got, want)
+	}
+
+	entries, err := trash.List(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != id || entries[0].Size != int64(len("docs/a.txt")) {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if expires := entries[0].DeletedAt.Add(30 * 24 * time.Hour); !entries[0].ExpiresAt.Equal(expires) {
+		t.Errorf("expires at %s, want %s", entries[0].ExpiresAt, expires)
+	}
+
+	if _, err := trash.Restore(ctx, client, id, storage.ConflictFail); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := stored(t, client); !reflect.DeepEqual(got, []string{"docs/a.txt", "docs/b.txt"}) {
+		t.Errorf("stored after the restore = %q", got)
+	}
+	if read(t, client, "docs/a.txt") != "docs/a.txt" {
+		t.Error("the restored file has other content")
+	}
+	if _, err := trash.Get(ctx, client, id); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("restored entry: err = %v, want ErrEntryNotFound", err)
+	}
+}
+
+func TestDeleteFolderMarker(t *testing.T) {
+	ctx := context.Background()
+	trash, client := newTestTrash(t, "docs/", "docs/a.txt")
+
+	// like a permanent delete, only the marker goes
+	result, err := trash.Delete(ctx, client, "docs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := visible(t, client); !reflect.DeepEqual(got, []string{"docs/a.txt"}) {
+		t.Errorf("visible = %q", got)
+	}
+
+	if _, err := trash.Restore(ctx, client, result.Entry.ID, storage.ConflictFail); err != nil {
+		t.Fatal(err)
+	}
+	if got := stored(t, client); !reflect.DeepEqual(got, []string{"docs/", "docs/a.txt"}) {
+		t.Errorf("stored after the restore = %q", got)
+	}
+}
+
+func TestRestoreConflicts(t *testing.T) {
+	ctx := context.Background()
+	trash, client := newTestTrash(t, "docs/", "docs/a.txt", "docs/b.txt")
+
+	result, err := trash.DeleteFolder(ctx, client, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Moved != 2 || result.Entry.Objects != 2 || !result.Entry.IsFolder {
+		t.Errorf("result = %+v, entry = %+v", result, result.Entry)
+	}
+	if got := visible(t, client); len(got) != 0 {
+		t.Errorf("visible = %q, want nothing", got)
+	}
+
+	// a new file took the place of a deleted one
+	if err := client.UploadFile(ctx, strings.NewReader("new"), "docs/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	id := result.Entry.ID
+	result, err = trash.Restore(ctx, client, id, storage.ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Moved != 1 || result.Failed != 1 || result.Failures[0].Key != ".trash/"+id+"/docs/a.txt" {
+		t.Errorf("restore with fail = %+v", result)
+	}
+	if _, err := trash.Get(ctx, client, id); err != nil {
+		t.Errorf("partly restored entry: %v", err)
+	}
+
+	result, err = trash.Restore(ctx, client, id, storage.ConflictRename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Moved != 1 || result.Failed != 0 {
+		t.Errorf("restore with rename = %+v", result)
+	}
+
+	want := []string{"docs/", "docs/a (1).txt", "docs/a.txt", "docs/b.txt"}
+	if got := stored(t, client); !reflect.DeepEqual(got, want) {
+		t.Errorf("stored = %q, want %q", got, want)
+	}
+	if read(t, client, "docs/a.txt") != "new" || read(t, client, "docs/a (1).txt") != "docs/a.txt" {
+		t.Error("the restore replaced the new file")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	trash, client := newTestTrash(t, "old.txt", "new.txt")
+
+	old, err := trash.Delete(ctx, client, "old.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	boundary := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := trash.Delete(ctx, client, "new.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the entry deleted before the retention period is purged
+	trash.retention = time.Since(boundary)
+	trash.purge(ctx)
+
+	entries, err := trash.List(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID == old.Entry.ID {
+		t.Fatalf("entries after the purge = %+v, want the new one", entries)
+	}
+
+	trash.retention = 0
+	trash.purge(ctx)
+
+	if got := stored(t, client); len(got) != 0 {
+		t.Errorf("stored after purging everything = %q, want nothing", got)
+	}
+}
+
+func TestEntryIDs(t *testing.T) {
+	ctx := context.Background()
+	trash, client := newTestTrash(t, "a.txt")
+
+	// manifests of other shapes in the trash are not entries
+	if err := client.UploadFile(ctx, strings.NewReader("{}"), ".trash/notes.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"", "../a", strings.Repeat("g", 32), "notes"} {
+		if _, err := trash.Get(ctx, client, id); !errors.Is(err, ErrEntryNotFound) {
+			t.Errorf("%q: err = %v, want ErrEntryNotFound", id, err)
+		}
+	}
+
+	result, err := trash.Delete(ctx, client, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if at := deletedAt(result.Entry.ID); !at.Equal(result.Entry.DeletedAt) {
+		t.Errorf("ID encodes %s, deleted at %s", at, result.Entry.DeletedAt)
+	}
+
+	ids, err := trash.ids(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{result.Entry.ID}) {
+		t.Errorf("ids = %q", ids)
+	}
+
+	if _, err := trash.Delete(ctx, client, ".trash/notes.json"); err == nil {
+		t.Error("deleted an object in the trash into the trash")
+	}
+}
@@ -0,0 +1,333 @@
+package tus
+
+import (
+	"encoding/json"
+	"errors"
+	"file-management-service/pkg/storage"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUploadNotFound is returned for unknown or expired upload IDs.
+var ErrUploadNotFound = errors.New("upload not found")
+
+// validID matches the IDs generated by the handler; anything else is rejected
+// before it gets near the file system.
+var validID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Upload is the persisted state of one resumable upload session.
+//
+// Received bytes are appended to a pending file on disk. Whenever a full part
+// has accumulated it is uploaded to the bucket, and the pending file is later
+// rewritten to only hold the bytes after the uploaded parts. PendingStart is
+// the upload offset of the first byte in the pending file, so the current
+// offset is PendingStart plus the size of that file.
+//
+// A completed upload keeps its state, without the pending file, so clients
+// can still look up its offset until the session expires.
+type Upload struct {
+	ID           string                  `json:"id"`
+	Bucket       string                  `json:"bucket"`
+	Key          string                  `json:"key"`
+	Length       int64                   `json:"length"`
+	Metadata     map[string]string       `json:"metadata,omitempty"`
+	MultipartID  string                  `json:"multipartId,omitempty"`
+	Parts        []storage.CompletedPart `json:"parts,omitempty"`
+	PartsSize    int64                   `json:"partsSize"`
+	PendingStart int64                   `json:"pendingStart"`
+	CreatedAt    time.Time               `json:"createdAt"`
+	CompletedAt  *time.Time              `json:"completedAt,omitempty"`
+
+	offset   int64
+	activeAt time.Time
+}
+
+// Offset returns the number of bytes received so far.
+func (u *Upload) Offset() int64 {
+	return u.offset
+}
+
+// Completed reports whether the object was stored in the bucket.
+func (u *Upload) Completed() bool {
+	return u.CompletedAt != nil
+}
+
+// ActiveAt returns when data was last received, or when the upload was
+// completed.
+func (u *Upload) ActiveAt() time.Time {
+	return u.activeAt
+}
+
+// Store persists upload sessions in a directory so they survive restarts.
+type Store struct {
+	dir   string
+	mutex sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewStore creates a Store keeping its files in dir.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		dir:   dir,
+		locks: make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// Lock serializes access to one upload. The returned function releases it.
+func (s *Store) Lock(id string) func() {
+	s.mutex.Lock()
+	lock, found := s.locks[id]
+	if !found {
+		lock = &sync.Mutex{}
+		s.locks[id] = lock
+	}
+	s.mutex.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// Create persists a new upload and its empty pending file.
+func (s *Store) Create(upload *Upload) error {
+	f, err := os.OpenFile(s.pendingPath(upload.ID, 0), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	upload.activeAt = upload.CreatedAt
+
+	return s.Save(upload)
+}
+
+// Get loads an upload and computes its current offset from the pending file.
+func (s *Store) Get(id string) (*Upload, error) {
+	if !validID.MatchString(id) {
+		return nil, ErrUploadNotFound
+	}
+
+	upload, info, err := s.load(id)
+
+	// Compact replaces the pending file after saving the new state, so a
+	// reader without the lock can find the file of the state it read gone
+	// and has to read the state again.
+	if errors.Is(err, fs.ErrNotExist) {
+		upload, info, err = s.load(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if upload.Completed() {
+		upload.offset = upload.Length
+		upload.activeAt = *upload.CompletedAt
+		return upload, nil
+	}
+
+	upload.offset = upload.PendingStart + info.Size()
+	upload.activeAt = info.ModTime()
+	if upload.activeAt.Before(upload.CreatedAt) {
+		upload.activeAt = upload.CreatedAt
+	}
+
+	return upload, nil
+}
+
+// load reads the state of an upload and stats its pending file, unless the
+// upload is completed.
+func (s *Store) load(id string) (*Upload, fs.FileInfo, error) {
+	data, err := os.ReadFile(s.statePath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	upload := &Upload{}
+	if err := json.Unmarshal(data, upload); err != nil {
+		return nil, nil, err
+	}
+
+	if upload.Completed() {
+		return upload, nil, nil
+	}
+
+	info, err := os.Stat(s.pendingPath(id, upload.PendingStart))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return upload, info, nil
+}
+
+// IDs lists the IDs of all stored uploads.
+func (s *Store) IDs() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, file := range files {
+		if id := strings.TrimSuffix(filepath.Base(file), ".json"); validID.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// Save atomically writes the state of an upload.
+func (s *Store) Save(upload *Upload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.statePath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.statePath(upload.ID))
+}
+
+// OpenPending opens the pending file of an upload for reading and appending.
+func (s *Store) OpenPending(upload *Upload) (*os.File, error) {
+	return os.OpenFile(s.pendingPath(upload.ID, upload.PendingStart), os.O_RDWR|os.O_APPEND, 0o644)
+}
+
+// Compact drops the bytes that were already uploaded as parts from the pending
+// file. The remainder is copied to a new pending file first, so a crash at any
+// point leaves a consistent state behind.
+func (s *Store) Compact(upload *Upload, pending *os.File) error {
+	if upload.PartsSize <= upload.PendingStart {
+		return nil
+	}
+
+	info, err := pending.Stat()
+	if err != nil {
+		return err
+	}
+
+	start := upload.PartsSize - upload.PendingStart
+	remainder := io.NewSectionReader(pending, start, info.Size()-start)
+
+	newPath := s.pendingPath(upload.ID, upload.PartsSize)
+	tmp, err := os.Create(newPath + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(tmp, remainder); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), newPath); err != nil {
+		return err
+	}
+
+	oldPath := s.pendingPath(upload.ID, upload.PendingStart)
+	upload.PendingStart = upload.PartsSize
+	if err := s.Save(upload); err != nil {
+		return err
+	}
+
+	return os.Remove(oldPath)
+}
+
+// Complete marks an upload as completed and removes its pending file.
+func (s *Store) Complete(upload *Upload) error {
+	completedAt := time.Now().UTC()
+	upload.CompletedAt = &completedAt
+	upload.activeAt = completedAt
+
+	if err := s.Save(upload); err != nil {
+		return err
+	}
+
+	return s.removePending(upload.ID)
+}
+
+// RemoveOrphans removes the files of uploads without a state that were last
+// modified before a point in time. A crash while creating an upload leaves
+// them behind.
+func (s *Store) RemoveOrphans(before time.Time) error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.bin*"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		id, _, _ := strings.Cut(filepath.Base(file), ".")
+		if _, err := os.Stat(s.statePath(id)); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if info, err := os.Stat(file); err == nil && info.ModTime().Before(before) {
+			os.Remove(file)
+		}
+	}
+
+	return nil
+}
+
+// Delete removes all files belonging to an upload.
+func (s *Store) Delete(id string) error {
+	// remove the state last, it is what makes the upload visible
+	if err := s.removePending(id); err != nil {
+		return err
+	}
+
+	err := os.Remove(s.statePath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	s.mutex.Lock()
+	delete(s.locks, id)
+	s.mutex.Unlock()
+
+	return err
+}
+
+// removePending removes the pending files of an upload.
+func (s *Store) removePending(id string) error {
+	files, err := filepath.Glob(filepath.Join(s.dir, id+".*"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file != s.statePath(id) {
+			os.Remove(file)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) statePath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *Store) pendingPath(id string, start int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s.%d.bin", id, start))
+}
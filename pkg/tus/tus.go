@@ -0,0 +1,637 @@
+package tus
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"file-management-service/config"
+	"file-management-service/pkg/storage"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Version is the only protocol version the handler speaks.
+const Version = "1.0.0"
+
+// Extensions lists the supported protocol extensions.
+const Extensions = "creation,termination,checksum,expiration"
+
+// cleanupInterval is how often expired sessions are removed.
+const cleanupInterval = 15 * time.Minute
+
+// finishTimeout bounds assembling the object once all bytes were received.
+// It doesn't depend on the request, a client that disconnects right after
+// the last chunk still gets its object.
+const finishTimeout = 10 * time.Minute
+
+// StatusChecksumMismatch is the tus specific status for a failed checksum.
+const StatusChecksumMismatch = 460
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// Handler implements the tus 1.0 resumable upload protocol (core, creation,
+// termination, checksum and expiration extensions). Sessions map onto
+// multipart uploads of the bucket they were created for and are persisted in
+// a Store, so an interrupted upload can be resumed after the service
+// restarts. A session expires once no data was received for the expiry
+// period, completed sessions are kept for as long.
+type Handler struct {
+	buckets  *storage.Registry
+	store    *Store
+	route    string
+	partSize int64
+	maxSize  int64
+	expiry   time.Duration
+	wg       sync.WaitGroup
+}
+
+// NewHandler creates a Handler serving uploads below route.
+func NewHandler(config *config.Config, buckets *storage.Registry, route string) (*Handler, error) {
+	store, err := NewStore(config.TusStateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{
+		buckets:  buckets,
+		store:    store,
+		route:    strings.TrimSuffix(route, "/") + "/",
+		partSize: int64(config.UploadPartSizeMB) * 1024 * 1024,
+		maxSize:  config.TusMaxSize,
+		expiry:   time.Duration(config.TusExpiryHours) * time.Hour,
+	}, nil
+}
+
+// Start removes expired sessions periodically until ctx is done.
+func (h *Handler) Start(ctx context.Context) {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			h.cleanup(ctx)
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close waits for a running cleanup to stop. Cancel the context passed to
+// Start first.
+func (h *Handler) Close() {
+	h.wg.Wait()
+}
+
+// Options reports the server's protocol capabilities.
+func (h *Handler) Options(c echo.Context) error {
+	header := c.Response().Header()
+	header.Set("Tus-Resumable", Version)
+	header.Set("Tus-Version", Version)
+	header.Set("Tus-Extension", Extensions)
+	header.Set("Tus-Checksum-Algorithm", "md5,sha1,sha256")
+	if h.maxSize > 0 {
+		header.Set("Tus-Max-Size", strconv.FormatInt(h.maxSize, 10))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Create starts a new upload. The object key is taken from the "filename"
+// and optional "path" entries of the Upload-Metadata header, the bucket from
+// the "bucket" query parameter.
+func (h *Handler) Create(c echo.Context) error {
+	if err := h.checkVersion(c); err != nil {
+		return err
+	}
+
+	client, err := h.buckets.Client(c.QueryParam("bucket"))
+	if err != nil {
+		return h.fail(c, http.StatusForbidden, err)
+	}
+
+	length, err := strconv.ParseInt(c.Request().Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return h.fail(c, http.StatusBadRequest, errors.New("Upload-Length header is required"))
+	}
+
+	if h.maxSize > 0 && length > h.maxSize {
+		return h.fail(c, http.StatusRequestEntityTooLarge, fmt.Errorf("upload exceeds the maximum size of %d bytes", h.maxSize))
+	}
+
+	metadata, err := parseMetadata(c.Request().Header.Get("Upload-Metadata"))
+	if err != nil {
+		return h.fail(c, http.StatusBadRequest, err)
+	}
+
+	fileName := path.Base(metadata["filename"])
+	if metadata["filename"] == "" || fileName == "/" || fileName == "." || fileName == ".." {
+		return h.fail(c, http.StatusBadRequest, errors.New("filename metadata is required"))
+	}
+
+	objectKey := fileName
+	if folderPath := strings.TrimSuffix(metadata["path"], "/"); folderPath != "" {
+		objectKey = folderPath + "/" + fileName
+	}
+
+	id, err := newID()
+	if err != nil {
+		return h.fail(c, http.StatusInternalServerError, err)
+	}
+
+	upload := &Upload{
+		ID:        id,
+		Bucket:    client.Name(),
+		Key:       objectKey,
+		Length:    length,
+		Metadata:  metadata,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := h.store.Create(upload); err != nil {
+		return h.fail(c, http.StatusInternalServerError, err)
+	}
+
+	// an empty upload is complete as soon as it is created
+	if length == 0 {
+		if err := h.finish(client, upload); err != nil {
+			return h.fail(c, http.StatusInternalServerError, err)
+		}
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, h.route+id)
+	c.Response().Header().Set("Tus-Resumable", Version)
+	h.setExpires(c, upload)
+	return c.NoContent(http.StatusCreated)
+}
+
+// Head reports the offset of an upload so the client knows where to resume.
+func (h *Handler) Head(c echo.Context) error {
+	if err := h.checkVersion(c); err != nil {
+		return err
+	}
+
+	upload, err := h.get(c.Param("id"))
+	if err != nil {
+		return h.failUpload(c, err)
+	}
+
+	// All bytes were received but assembling the object failed. Clients take
+	// a full offset as done, so finishing is retried before it is reported.
+	if upload.Offset() == upload.Length && !upload.Completed() {
+		upload, err = h.retryFinish(c.Param("id"))
+		if err != nil {
+			return h.failUpload(c, err)
+		}
+	}
+
+	header := c.Response().Header()
+	header.Set("Tus-Resumable", Version)
+	header.Set("Upload-Offset", strconv.FormatInt(upload.Offset(), 10))
+	header.Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	header.Set(echo.HeaderCacheControl, "no-store")
+	h.setExpires(c, upload)
+
+	return c.NoContent(http.StatusOK)
+}
+
+// Patch appends a chunk of data at the current offset. Once all bytes have
+// been received the object is assembled in the bucket and the session is
+// marked as completed.
+func (h *Handler) Patch(c echo.Context) error {
+	if err := h.checkVersion(c); err != nil {
+		return err
+	}
+
+	req := c.Request()
+	if req.Header.Get(echo.HeaderContentType) != "application/offset+octet-stream" {
+		return h.fail(c, http.StatusUnsupportedMediaType, errors.New("Content-Type must be application/offset+octet-stream"))
+	}
+
+	offset, err := strconv.ParseInt(req.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return h.fail(c, http.StatusBadRequest, errors.New("Upload-Offset header is required"))
+	}
+
+	var checksum hash.Hash
+	var expected []byte
+	if value := req.Header.Get("Upload-Checksum"); value != "" {
+		checksum, expected, err = parseChecksum(value)
+		if err != nil {
+			return h.fail(c, http.StatusBadRequest, err)
+		}
+	}
+
+	unlock := h.store.Lock(c.Param("id"))
+	defer unlock()
+
+	upload, err := h.get(c.Param("id"))
+	if err != nil {
+		return h.failUpload(c, err)
+	}
+
+	if offset != upload.Offset() {
+		return h.fail(c, http.StatusConflict, fmt.Errorf("offset %d does not match the upload offset %d", offset, upload.Offset()))
+	}
+
+	// a retried final chunk whose response was lost
+	if upload.Completed() {
+		c.Response().Header().Set("Tus-Resumable", Version)
+		c.Response().Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset(), 10))
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	client, err := h.buckets.Client(upload.Bucket)
+	if err != nil {
+		return h.fail(c, http.StatusForbidden, err)
+	}
+
+	pending, err := h.store.OpenPending(upload)
+	if err != nil {
+		return h.fail(c, http.StatusInternalServerError, err)
+	}
+	defer pending.Close()
+
+	// never accept more bytes than announced in Upload-Length
+	remaining := upload.Length - upload.Offset()
+	var dst io.Writer = pending
+	if checksum != nil {
+		dst = io.MultiWriter(pending, checksum)
+	}
+
+	written, copyErr := io.Copy(dst, io.LimitReader(req.Body, remaining+1))
+	if written > remaining {
+		pending.Truncate(upload.Offset() - upload.PendingStart)
+		return h.fail(c, http.StatusRequestEntityTooLarge, errors.New("chunk exceeds the upload length"))
+	}
+
+	// A chunk with a checksum is all or nothing. Without one, whatever was
+	// received before the connection dropped is kept and can be resumed.
+	if checksum != nil && (copyErr != nil || !bytes.Equal(checksum.Sum(nil), expected)) {
+		pending.Truncate(upload.Offset() - upload.PendingStart)
+		if copyErr != nil {
+			return h.fail(c, http.StatusBadRequest, copyErr)
+		}
+		return h.fail(c, StatusChecksumMismatch, errors.New("checksum mismatch"))
+	}
+
+	upload.offset += written
+
+	if upload.Offset() == upload.Length {
+		if err := h.finish(client, upload); err != nil {
+			return h.fail(c, http.StatusInternalServerError, err)
+		}
+	} else if err := h.flush(c, client, upload, pending); err != nil {
+		return h.fail(c, http.StatusInternalServerError, err)
+	}
+
+	if copyErr != nil {
+		return h.fail(c, http.StatusBadRequest, copyErr)
+	}
+
+	c.Response().Header().Set("Tus-Resumable", Version)
+	c.Response().Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset(), 10))
+	if !upload.Completed() {
+		upload.activeAt = time.Now()
+		h.setExpires(c, upload)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Terminate cancels an upload and discards everything received so far.
+func (h *Handler) Terminate(c echo.Context) error {
+	if err := h.checkVersion(c); err != nil {
+		return err
+	}
+
+	unlock := h.store.Lock(c.Param("id"))
+	defer unlock()
+
+	upload, err := h.get(c.Param("id"))
+	if err != nil {
+		return h.failUpload(c, err)
+	}
+
+	// the object of a completed upload stays, only the session ends
+	if err := h.discard(c.Request().Context(), upload); err != nil {
+		return h.fail(c, http.StatusInternalServerError, err)
+	}
+
+	c.Response().Header().Set("Tus-Resumable", Version)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// flush uploads every complete part in the pending file, then drops the
+// uploaded bytes from it. Backends without multipart support keep all data in
+// the pending file until the upload is finished.
+func (h *Handler) flush(c echo.Context, client *storage.Client, upload *Upload, pending *os.File) error {
+	uploader, ok := client.Backend().(storage.MultipartUploader)
+	if !ok {
+		return nil
+	}
+
+	if err := h.uploadParts(c.Request().Context(), uploader, upload, pending, false); err != nil {
+		return err
+	}
+
+	return h.store.Compact(upload, pending)
+}
+
+// uploadParts uploads the data between the last uploaded part and the current
+// offset in parts of partSize. With final set the trailing short part is
+// uploaded as well.
+func (h *Handler) uploadParts(ctx context.Context, uploader storage.MultipartUploader, upload *Upload, pending io.ReaderAt, final bool) error {
+	for {
+		available := upload.Offset() - upload.PartsSize
+		if available == 0 || (available < h.partSize && !final) {
+			return nil
+		}
+
+		size := h.partSize
+		if available < size {
+			size = available
+		}
+
+		if upload.MultipartID == "" {
+			id, err := uploader.CreateMultipartUpload(ctx, upload.Key)
+			if err != nil {
+				return err
+			}
+
+			upload.MultipartID = id
+			if err := h.store.Save(upload); err != nil {
+				return err
+			}
+		}
+
+		number := len(upload.Parts) + 1
+		body := io.NewSectionReader(pending, upload.PartsSize-upload.PendingStart, size)
+
+		etag, err := uploader.UploadPart(ctx, upload.Key, upload.MultipartID, number, body)
+		if err != nil {
+			return err
+		}
+
+		upload.Parts = append(upload.Parts, storage.CompletedPart{Number: number, ETag: etag, Size: size})
+		upload.PartsSize += size
+
+		if err := h.store.Save(upload); err != nil {
+			return err
+		}
+	}
+}
+
+// finish stores the complete object in the bucket and completes the session.
+// Uploads that never filled a part are written with a single Put.
+func (h *Handler) finish(client *storage.Client, upload *Upload) error {
+	ctx, cancel := context.WithTimeout(context.Background(), finishTimeout)
+	defer cancel()
+
+	pending, err := h.store.OpenPending(upload)
+	if err != nil {
+		return err
+	}
+	defer pending.Close()
+
+	if upload.MultipartID == "" {
+		body := io.NewSectionReader(pending, 0, upload.Length-upload.PendingStart)
+		if err := client.UploadFile(ctx, body, upload.Key); err != nil {
+			return err
+		}
+	} else {
+		uploader, ok := client.Backend().(storage.MultipartUploader)
+		if !ok {
+			return fmt.Errorf("bucket %q does not support multipart uploads", upload.Bucket)
+		}
+
+		if err := h.uploadParts(ctx, uploader, upload, pending, true); err != nil {
+			return err
+		}
+
+		if err := uploader.CompleteMultipartUpload(ctx, upload.Key, upload.MultipartID, upload.Parts); err != nil {
+			return err
+		}
+
+		client.Stored(ctx, upload.Key)
+	}
+
+	return h.store.Complete(upload)
+}
+
+// retryFinish finishes an upload whose bytes were all received, unless a
+// concurrent request did so already.
+func (h *Handler) retryFinish(id string) (*Upload, error) {
+	unlock := h.store.Lock(id)
+	defer unlock()
+
+	upload, err := h.get(id)
+	if err != nil || upload.Completed() {
+		return upload, err
+	}
+
+	client, err := h.buckets.Client(upload.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.finish(client, upload); err != nil {
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// get loads an upload, expired ones are reported as not found even before
+// they are cleaned up.
+func (h *Handler) get(id string) (*Upload, error) {
+	upload, err := h.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.expired(upload, time.Now()) {
+		return nil, ErrUploadNotFound
+	}
+
+	return upload, nil
+}
+
+func (h *Handler) expired(upload *Upload, now time.Time) bool {
+	return !upload.ActiveAt().Add(h.expiry).After(now)
+}
+
+// setExpires tells the client until when an unfinished upload can be resumed.
+func (h *Handler) setExpires(c echo.Context, upload *Upload) {
+	if upload.Completed() {
+		return
+	}
+
+	c.Response().Header().Set("Upload-Expires", upload.ActiveAt().Add(h.expiry).UTC().Format(http.TimeFormat))
+}
+
+// discard aborts the multipart upload of an unfinished upload and removes
+// the session.
+func (h *Handler) discard(ctx context.Context, upload *Upload) error {
+	if upload.MultipartID != "" && !upload.Completed() {
+		client, err := h.buckets.Client(upload.Bucket)
+		if err != nil {
+			return err
+		}
+
+		if uploader, ok := client.Backend().(storage.MultipartUploader); ok {
+			if err := uploader.AbortMultipartUpload(ctx, upload.Key, upload.MultipartID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return h.store.Delete(upload.ID)
+}
+
+// cleanup removes expired sessions along with their pending files and
+// multipart uploads.
+func (h *Handler) cleanup(ctx context.Context) {
+	now := time.Now()
+
+	ids, err := h.store.IDs()
+	if err != nil {
+		log.Printf("Failed to list resumable uploads: %s", err)
+		return
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+
+		unlock := h.store.Lock(id)
+		upload, err := h.store.Get(id)
+		if err == nil && h.expired(upload, now) {
+			err = h.discard(ctx, upload)
+		}
+		unlock()
+
+		if err != nil && !errors.Is(err, ErrUploadNotFound) && ctx.Err() == nil {
+			log.Printf("Failed to remove expired upload %s: %s", id, err)
+		}
+	}
+
+	if err := h.store.RemoveOrphans(now.Add(-h.expiry)); err != nil {
+		log.Printf("Failed to remove orphaned upload files: %s", err)
+	}
+}
+
+// checkVersion rejects requests for protocol versions other than 1.0.0.
+func (h *Handler) checkVersion(c echo.Context) error {
+	if c.Request().Header.Get("Tus-Resumable") != Version {
+		c.Response().Header().Set("Tus-Version", Version)
+		return h.fail(c, http.StatusPreconditionFailed, errors.New("unsupported tus version"))
+	}
+
+	return nil
+}
+
+func (h *Handler) failUpload(c echo.Context, err error) error {
+	if errors.Is(err, ErrUploadNotFound) {
+		return h.fail(c, http.StatusNotFound, err)
+	}
+
+	return h.fail(c, http.StatusInternalServerError, err)
+}
+
+func (h *Handler) fail(c echo.Context, status int, err error) error {
+	c.Response().Header().Set("Tus-Resumable", Version)
+
+	// HEAD responses must not have a body
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(status)
+	}
+
+	return c.JSON(status, storage.GetFailureResponse(err))
+}
+
+// parseMetadata decodes an Upload-Metadata header: comma separated pairs of a
+// key and an optional base64 encoded value.
+func parseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if header == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid Upload-Metadata pair %q", pair)
+		}
+
+		value := ""
+		if len(fields) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid Upload-Metadata value for %q", fields[0])
+			}
+			value = string(decoded)
+		}
+
+		metadata[fields[0]] = value
+	}
+
+	return metadata, nil
+}
+
+// parseChecksum decodes an Upload-Checksum header of the form
+// "<algorithm> <base64 digest>".
+func parseChecksum(header string) (hash.Hash, []byte, error) {
+	fields := strings.Fields(header)
+	if len(fields) != 2 {
+		return nil, nil, errors.New("invalid Upload-Checksum header")
+	}
+
+	newHash, found := checksumAlgorithms[fields[0]]
+	if !found {
+		return nil, nil, fmt.Errorf("unsupported checksum algorithm %q", fields[0])
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, nil, errors.New("invalid Upload-Checksum digest")
+	}
+
+	return newHash(), expected, nil
+}
+
+func newID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,153 @@
+package tus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"file-management-service/config"
+	"file-management-service/pkg/memory"
+	"file-management-service/pkg/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+// flakyBackend fails every Put while down is set.
+type flakyBackend struct {
+	storage.Storage
+	down bool
+}
+
+func (b *flakyBackend) Put(ctx context.Context, key string, body io.Reader) error {
+	if b.down {
+		return errors.New("backend is down")
+	}
+	return b.Storage.Put(ctx, key, body)
+}
+
+// newTestServer serves uploads into an in-memory bucket through backend.
+func newTestServer(t *testing.T) (*echo.Echo, *flakyBackend) {
+	t.Helper()
+
+	appConfig := &config.Config{
+		Name:             "test",
+		PublicURL:        "http://localhost",
+		URLSigningKey:    "secret",
+		UploadPartSizeMB: 5,
+		TusStateDir:      t.TempDir(),
+		TusExpiryHours:   24,
+	}
+
+	memoryBackend, err := memory.NewClient(appConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &flakyBackend{Storage: memoryBackend}
+
+	buckets := storage.NewRegistry("test")
+	buckets.Register(storage.NewClient("test", backend))
+
+	handler, err := NewHandler(appConfig, buckets, "/tus/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.POST("/tus/", handler.Create)
+	e.HEAD("/tus/:id", handler.Head)
+	e.PATCH("/tus/:id", handler.Patch)
+
+	return e, backend
+}
+
+func request(e *echo.Echo, method string, target string, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Tus-Resumable", Version)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// create starts an upload of a.txt and returns its location.
+func create(t *testing.T, e *echo.Echo, length string) string {
+	t.Helper()
+
+	rec := request(e, http.MethodPost, "/tus/", "", "Upload-Length", length, "Upload-Metadata", "filename YS50eHQ=")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	return rec.Header().Get(echo.HeaderLocation)
+}
+
+func patch(e *echo.Echo, location string, offset string, body string) *httptest.ResponseRecorder {
+	return request(e, http.MethodPatch, location, body,
+		"Upload-Offset", offset,
+		echo.HeaderContentType, "application/offset+octet-stream",
+	)
+}
+
+func TestUpload(t *testing.T) {
+	e, backend := newTestServer(t)
+	location := create(t, e, "5")
+
+	if rec := patch(e, location, "0", "abc"); rec.Code != http.StatusNoContent || rec.Header().Get("Upload-Offset") != "3" {
+		t.Fatalf("first chunk: %d, offset %s", rec.Code, rec.Header().Get("Upload-Offset"))
+	}
+	if rec := patch(e, location, "0", "abc"); rec.Code != http.StatusConflict {
+		t.Errorf("chunk at a stale offset: %d, want 409", rec.Code)
+	}
+	if rec := patch(e, location, "3", "de"); rec.Code != http.StatusNoContent {
+		t.Fatalf("last chunk: %d %s", rec.Code, rec.Body)
+	}
+
+	// the completed session still reports its offset
+	rec := request(e, http.MethodHead, location, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Upload-Offset") != "5" {
+		t.Errorf("HEAD after completion: %d, offset %s", rec.Code, rec.Header().Get("Upload-Offset"))
+	}
+
+	body, err := backend.Get(context.Background(), "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	if data, _ := io.ReadAll(body); string(data) != "abcde" {
+		t.Errorf("stored %q", data)
+	}
+}
+
+func TestHeadRetriesFinish(t *testing.T) {
+	e, backend := newTestServer(t)
+	location := create(t, e, "5")
+
+	// the last chunk arrives, but the object cannot be stored
+	backend.down = true
+	if rec := patch(e, location, "0", "abcde"); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("chunk while the backend is down: %d", rec.Code)
+	}
+
+	// a full offset would end the upload on the client, so it is not
+	// reported while the object is missing
+	if rec := request(e, http.MethodHead, location, ""); rec.Code != http.StatusInternalServerError {
+		t.Errorf("HEAD while the backend is down: %d, offset %s", rec.Code, rec.Header().Get("Upload-Offset"))
+	}
+
+	backend.down = false
+	rec := request(e, http.MethodHead, location, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Upload-Offset") != "5" {
+		t.Fatalf("HEAD after recovery: %d, offset %s", rec.Code, rec.Header().Get("Upload-Offset"))
+	}
+
+	if _, err := backend.Stat(context.Background(), "a.txt"); err != nil {
+		t.Errorf("the object was not stored: %v", err)
+	}
+}
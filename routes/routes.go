@@ -1,52 +1,189 @@
 package routes
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"file-management-service/config"
 	"file-management-service/pkg/cache"
-	"file-management-service/pkg/s3"
+	"file-management-service/pkg/filter"
+	"file-management-service/pkg/index"
+	"file-management-service/pkg/jobs"
+	"file-management-service/pkg/storage"
+	"file-management-service/pkg/trash"
+	"file-management-service/pkg/tus"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
 // RegisterRoutes registers all the routes for the application
-func RegisterRoutes(e *echo.Echo, config *config.Config, cache *cache.URLCache) {
+func RegisterRoutes(e *echo.Echo, config *config.Config, buckets *storage.Registry, uploads *tus.Handler, indexer *index.Indexer, cache *cache.URLCache, usageCache *cache.TTLCache[*storage.FolderUsage], cursors *storage.CursorSigner, jobManager *jobs.Manager, trashBin *trash.Trash) {
 	// Define route for uploading images
 	e.POST("/upload", func(c echo.Context) error {
-		return uploadFileHandler(c, config)
+		return uploadFileHandler(c, buckets)
+	})
+
+	// Presigned URLs for uploading directly to the bucket
+	e.GET("/upload-url", func(c echo.Context) error {
+		return uploadURLHandler(c, buckets)
 	})
 
 	// Define route for serving files
 	e.GET("/download", func(c echo.Context) error {
-		return downloadFileHandler(c, config, cache)
+		return downloadFileHandler(c, buckets, cache)
+	})
+
+	// Stream files through the service, with support for range requests
+	e.GET("/stream", func(c echo.Context) error {
+		return streamFileHandler(c, buckets)
+	})
+	e.HEAD("/stream", func(c echo.Context) error {
+		return streamFileHandler(c, buckets)
+	})
+
+	// Metadata of a single object
+	e.GET("/stat", func(c echo.Context) error {
+		return statHandler(c, buckets)
+	})
+	e.HEAD("/stat", func(c echo.Context) error {
+		return statHandler(c, buckets)
 	})
 
 	// Delete File
 	e.DELETE("/delete", func(c echo.Context) error {
-		return deleteFileHandler(c, config, cache)
+		return deleteFileHandler(c, buckets, trashBin)
 	})
 
 	// Delete File
 	e.DELETE("/delete-folder", func(c echo.Context) error {
-		return deleteFolderHandler(c, config)
+		return deleteFolderHandler(c, buckets, trashBin)
+	})
+
+	// Delete many keys and prefixes with one request
+	e.POST("/delete-bulk", func(c echo.Context) error {
+		return bulkDeleteHandler(c, buckets)
+	})
+
+	// Soft deleted files and folders
+	e.GET("/trash", func(c echo.Context) error {
+		return listTrashHandler(c, buckets, trashBin)
+	})
+
+	e.POST("/trash/:id/restore", func(c echo.Context) error {
+		return restoreTrashHandler(c, buckets, trashBin)
+	})
+
+	e.DELETE("/trash/:id", func(c echo.Context) error {
+		return removeTrashHandler(c, buckets, trashBin)
+	})
+
+	e.DELETE("/trash", func(c echo.Context) error {
+		return emptyTrashHandler(c, buckets, trashBin)
 	})
 
 	// List files within current folder
 	e.GET("/list", func(c echo.Context) error {
-		return listFilesHandler(c, config, cache)
+		return listFilesHandler(c, config, buckets, cache, cursors)
+	})
+
+	// Stream all files below a folder, recursively
+	e.GET("/list-recursive", func(c echo.Context) error {
+		return listAllFilesHandler(c, buckets)
 	})
 
 	// list all folders within current folder
 	e.GET("/list-folders", func(c echo.Context) error {
-		return listAllFoldersHandler(c, config)
+		return listAllFoldersHandler(c, buckets)
+	})
+
+	// Nested folder hierarchy with child counts
+	e.GET("/tree", func(c echo.Context) error {
+		return folderTreeHandler(c, buckets)
+	})
+
+	// Move or rename a file
+	e.POST("/move", func(c echo.Context) error {
+		return moveFileHandler(c, buckets, cache)
+	})
+
+	// Move or rename a folder in a background job
+	e.POST("/move-folder", func(c echo.Context) error {
+		return moveFolderHandler(c, buckets, jobManager)
+	})
+
+	// Copy a file, or a folder in a background job, possibly to another bucket
+	e.POST("/copy", func(c echo.Context) error {
+		return copyFileHandler(c, buckets, cache)
+	})
+
+	e.POST("/copy-folder", func(c echo.Context) error {
+		return copyFolderHandler(c, buckets, jobManager)
+	})
+
+	// Progress of background jobs
+	e.GET("/jobs/:id", func(c echo.Context) error {
+		return jobHandler(c, jobManager)
+	})
+
+	e.POST("/jobs/:id/resume", func(c echo.Context) error {
+		return resumeJobHandler(c, jobManager)
 	})
 
 	e.POST("/create-folder", func(c echo.Context) error {
-		return createFolderHandler(c, config)
+		return createFolderHandler(c, buckets)
+	})
+
+	// Serve links signed by backends that don't have their own download URLs
+	e.GET("/files/*", func(c echo.Context) error {
+		return serveSignedFileHandler(c, buckets)
+	})
+	e.HEAD("/files/*", func(c echo.Context) error {
+		return serveSignedFileHandler(c, buckets)
+	})
+
+	// Resumable uploads using the tus protocol
+	e.OPTIONS("/tus", uploads.Options)
+	e.OPTIONS("/tus/*", uploads.Options)
+	e.POST("/tus", uploads.Create)
+	e.POST("/tus/", uploads.Create)
+	e.HEAD("/tus/:id", uploads.Head)
+	e.PATCH("/tus/:id", uploads.Patch)
+	e.DELETE("/tus/:id", uploads.Terminate)
+
+	// Summarize the storage used below a folder
+	e.GET("/usage", func(c echo.Context) error {
+		return usageHandler(c, buckets, usageCache)
+	})
+
+	// Search the metadata index of a bucket
+	e.GET("/search", func(c echo.Context) error {
+		return searchHandler(c, buckets, indexer)
+	})
+
+	// Search the contents of text documents
+	e.GET("/search/text", func(c echo.Context) error {
+		return searchTextHandler(c, buckets, indexer)
+	})
+
+	// Crawl a bucket again to pick up changes made outside the service
+	e.POST("/index/rebuild", func(c echo.Context) error {
+		return rebuildIndexHandler(c, buckets, indexer)
+	})
+
+	// List the buckets clients can select
+	e.GET("/buckets", func(c echo.Context) error {
+		return listBucketsHandler(c, buckets)
 	})
 
 	// Define route for testing the server
@@ -54,13 +191,18 @@ func RegisterRoutes(e *echo.Echo, config *config.Config, cache *cache.URLCache)
 }
 
 // Handler to create folder
-// createFolderHandler is a handler function for creating a folder in S3
-func createFolderHandler(c echo.Context, config *config.Config) error {
+// createFolderHandler is a handler function for creating a folder in the bucket
+func createFolderHandler(c echo.Context, buckets *storage.Registry) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
 
 	folderName := c.QueryParam("path")
 
 	if folderName == "" {
-		response := s3.GetFailureResponse(errors.New("folder path is required and should end with /"))
+		response := storage.GetFailureResponse(errors.New("folder path is required and should end with /"))
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
@@ -68,63 +210,73 @@ func createFolderHandler(c echo.Context, config *config.Config) error {
 		folderName = folderName + "/"
 	}
 
-	// Create a new S3 client using your desired bucket name and region
-	client, err := s3.NewClient(config)
-	if err != nil {
-		// Handle error creating S3 client
-		response := s3.GetFailureResponse(errors.New("failed to create S3 client"))
-		return c.JSON(http.StatusInternalServerError, response)
-	}
-
 	// Call the CreateFolder function to create the folder
-	err = client.CreateFolder(folderName)
+	err = client.CreateFolder(c.Request().Context(), folderName)
 	if err != nil {
 		// Handle error creating folder
-		response := s3.GetFailureResponse(errors.New("failed to create folder"))
+		response := storage.GetFailureResponse(errors.New("failed to create folder"))
 		return c.JSON(http.StatusInternalServerError, response)
 	}
-	response := s3.GetSuccessResponse("Folder created successfully")
+	response := storage.GetSuccessResponse("Folder created successfully")
 	return c.JSON(http.StatusOK, response)
 }
 
 // Handler for image upload
-func uploadFileHandler(c echo.Context, config *config.Config) error {
-	folderPath := c.FormValue("path")
-	file, err := c.FormFile("file")
-
+func uploadFileHandler(c echo.Context, buckets *storage.Registry) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
 	if err != nil {
-		// Handle the error and return an error response
-		errorMessage := fmt.Sprintf("Failed to retrieve uploaded file: %s", err.Error())
-		response := s3.GetFailureResponse(errors.New(errorMessage))
-		return c.JSON(http.StatusInternalServerError, response)
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
 	}
 
-	// Open the file
-	src, err := file.Open()
+	// Read the multipart body as a stream instead of parsing the whole form,
+	// so large files go straight through to the bucket without being
+	// buffered in memory or on disk. The "path" field has to come before the
+	// "file" field, or be passed as a query parameter.
+	reader, err := c.Request().MultipartReader()
 	if err != nil {
 		// Handle the error and return an error response
-		errorMessage := fmt.Sprintf("Failed to open uploaded file: %s", err.Error())
-		response := s3.GetFailureResponse(errors.New(errorMessage))
-		return c.JSON(http.StatusInternalServerError, response)
+		errorMessage := fmt.Sprintf("Failed to read multipart upload: %s", err.Error())
+		response := storage.GetFailureResponse(errors.New(errorMessage))
+		return c.JSON(http.StatusBadRequest, response)
 	}
-	defer func() {
-		if closeErr := src.Close(); closeErr != nil {
-			// Handle the error (optional)
-			fmt.Println("Failed to close uploaded file:", closeErr)
+
+	folderPath := c.QueryParam("path")
+
+	var src *multipart.Part
+	for src == nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			response := storage.GetFailureResponse(errors.New("Failed to retrieve uploaded file: file is required"))
+			return c.JSON(http.StatusBadRequest, response)
+		}
+		if err != nil {
+			// Handle the error and return an error response
+			errorMessage := fmt.Sprintf("Failed to retrieve uploaded file: %s", err.Error())
+			response := storage.GetFailureResponse(errors.New(errorMessage))
+			return c.JSON(http.StatusBadRequest, response)
 		}
-	}()
 
-	// Create a new S3 client
-	client, err := s3.NewClient(config)
-	if err != nil {
-		// Handle the error and return an error response
-		errorMessage := fmt.Sprintf("Failed to create S3 client: %s", err.Error())
-		response := s3.GetFailureResponse(errors.New(errorMessage))
-		return c.JSON(http.StatusInternalServerError, response)
+		switch part.FormName() {
+		case "path":
+			value, err := io.ReadAll(io.LimitReader(part, 1024))
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+			}
+			folderPath = string(value)
+		case "file":
+			src = part
+		}
 	}
+	defer src.Close()
 
 	// Use the file name as it is as the object key
-	objectKey := file.Filename
+	objectKey := src.FileName()
+	if objectKey == "" {
+		response := storage.GetFailureResponse(errors.New("Failed to retrieve uploaded file: file name is required"))
+		return c.JSON(http.StatusBadRequest, response)
+	}
+
 	// Add the folder details
 	if folderPath != "" {
 		if string(folderPath[len(folderPath)-1]) == "/" {
@@ -134,115 +286,465 @@ func uploadFileHandler(c echo.Context, config *config.Config) error {
 		}
 	}
 
-	// Upload the file to S3
-	err = client.UploadFile(src, objectKey)
+	// Stream the file to the bucket. The request context is cancelled when
+	// the client disconnects, which aborts a multipart upload in progress.
+	err = client.UploadFile(c.Request().Context(), src, objectKey)
 	if err != nil {
 		// Handle the error and return an error response
-		errorMessage := fmt.Sprintf("Failed to upload file to S3: %s", err.Error())
-		response := s3.GetFailureResponse(errors.New(errorMessage))
+		errorMessage := fmt.Sprintf("Failed to upload file: %s", err.Error())
+		response := storage.GetFailureResponse(errors.New(errorMessage))
 		return c.JSON(http.StatusInternalServerError, response)
 	}
 
 	// Return a success response
 	successMessage := fmt.Sprintf("File uploaded successfully with object key: %s", objectKey)
-	response := s3.GetSuccessResponse(successMessage)
+	response := storage.GetSuccessResponse(successMessage)
 	// Return the array of file and folder information as JSON response
 	return c.JSON(http.StatusOK, response)
 }
 
+// Handler returning a presigned upload URL and POST policy for a file, so the
+// client can upload straight to the bucket
+func uploadURLHandler(c echo.Context, buckets *storage.Registry) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	presigner, ok := client.Backend().(storage.UploadPresigner)
+	if !ok {
+		response := storage.GetFailureResponse(fmt.Errorf("bucket %q does not support direct uploads", client.Name()))
+		return c.JSON(http.StatusNotImplemented, response)
+	}
+
+	folderPath := c.QueryParam("path")
+	fileName := filepath.Base(c.QueryParam("fileName"))
+
+	if c.QueryParam("fileName") == "" || fileName == "/" || fileName == "." || fileName == ".." {
+		response := storage.GetFailureResponse(errors.New("fileName is required"))
+		return c.JSON(http.StatusBadRequest, response)
+	}
+
+	input := storage.PresignUploadInput{
+		ContentType: c.QueryParam("contentType"),
+		Expiry:      15 * time.Minute,
+	}
+
+	if value := c.QueryParam("maxSize"); value != "" {
+		input.MaxSize, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || input.MaxSize <= 0 {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("maxSize must be a positive number of bytes")))
+		}
+	}
+
+	if value := c.QueryParam("size"); value != "" {
+		input.Size, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || input.Size < 0 || (input.MaxSize > 0 && input.Size > input.MaxSize) {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("size must be a number of bytes not larger than maxSize")))
+		}
+	}
+
+	// SigV4 presigned requests are valid for at most 7 days
+	if value := c.QueryParam("expiry"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 || seconds > 7*24*60*60 {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("expiry must be between 1 second and 7 days")))
+		}
+		input.Expiry = time.Duration(seconds) * time.Second
+	}
+
+	// Use the file name as it is as the object key
+	objectKey := fileName
+	if folderPath != "" {
+		objectKey = strings.TrimSuffix(folderPath, "/") + "/" + fileName
+	}
+
+	upload, err := presigner.PresignUpload(objectKey, input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         upload,
+	})
+}
+
+// listingParams are the query parameters that define a listing. Cursors pin
+// them, so later pages are always of the same listing.
+var listingParams = []string{
+	"path", "isFolder", "pageSize", "sortBy", "order", "filter", "tz", "from", "to",
+	"sizeRange", "timeRange", "minSize", "maxSize", "fileTypes",
+	"filenameQuery", "filenameFilterType", "fileSize", "fileSizeFilterType",
+}
+
 // List all files and folders within a folder
-func listFilesHandler(c echo.Context, config *config.Config, cache *cache.URLCache) error {
+func listFilesHandler(c echo.Context, config *config.Config, buckets *storage.Registry, cache *cache.URLCache, cursors *storage.CursorSigner) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	query := url.Values{}
+	for _, name := range listingParams {
+		if values := c.QueryParams()[name]; len(values) > 0 && values[0] != "" {
+			query[name] = values
+		}
+	}
+
+	position := storage.Cursor{Bucket: client.Name(), Query: query.Encode()}
+
+	// A cursor continues the listing it was issued for. Parameters may be
+	// repeated alongside it, but not changed.
+	cursorValue := c.QueryParam("cursor")
+	if cursorValue == "" {
+		cursorValue = c.Request().Header.Get("x-next")
+	}
+
+	if cursorValue != "" {
+		cursor, err := cursors.Decode(cursorValue)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+		}
+
+		issued, err := url.ParseQuery(cursor.Query)
+		if err != nil || cursor.Bucket != client.Name() {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(storage.ErrCursorMismatch))
+		}
+
+		for name, values := range query {
+			if strings.Join(values, "\x00") != strings.Join(issued[name], "\x00") {
+				return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(storage.ErrCursorMismatch))
+			}
+		}
+
+		query, position = issued, *cursor
+	}
 
 	// bool
-	isFolder, err := strconv.ParseBool(c.QueryParam("isFolder"))
+	isFolder, err := strconv.ParseBool(query.Get("isFolder"))
 	if err != nil {
 		isFolder = false
 	}
 
-	folderPath := c.QueryParam("path")
-
-	// Next page token for pagination
-	nextPageToken := c.Request().Header.Get("x-next")
+	folderPath := query.Get("path")
 
 	// Page size for pagination
-	pageSize, err := strconv.Atoi(c.QueryParam("pageSize"))
-	if err != nil {
+	pageSize, err := strconv.Atoi(query.Get("pageSize"))
+	if err != nil || pageSize <= 0 {
 		pageSize = config.PaginationPageSize
 	}
 
-	// Create a new S3 client
-	client, err := s3.NewClient(config) // Update with your desired region
-
+	options, filtered, err := parseFilterOptions(query)
 	if err != nil {
-		response := s3.GetFailureResponse(err)
-		return c.JSON(http.StatusInternalServerError, response)
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	// Sorting and filtering need the whole folder, pages are then cut from the
+	// sorted result so that every page continues the same sequence
+	if filtered || query.Get("sortBy") != "" || query.Get("order") != "" {
+		files, err := client.ListFolder(c.Request().Context(), folderPath, isFolder)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+		}
+
+		if filtered {
+			files = *storage.FilterFiles(files, options)
+		}
+
+		sorted := *storage.SortFilesBy(files, query.Get("sortBy"), query.Get("order"))
+		objects, err := client.PageFiles(sorted, position.Offset, pageSize, cache)
+		if errors.Is(err, storage.ErrInvalidToken) {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+		}
+
+		// the whole folder is listed anyway, so the total comes for free
+		total := len(sorted)
+		objects.TotalCount = &total
+
+		if !objects.IsLastPage {
+			next := position
+			next.Offset += int(objects.NoOfRecordsReturned)
+			objects.NextPageToken = cursors.Encode(next)
+		}
+
+		return c.JSON(http.StatusOK, storage.GetListFolderSuccessResponse(objects))
 	}
 
 	// List all the files and folders within the nested folder
-	objects, err := client.ListFiles(folderPath, nextPageToken, pageSize, isFolder, cache)
+	objects, err := client.ListFiles(c.Request().Context(), folderPath, position.Token, pageSize, isFolder, cache)
 
 	if err != nil {
-		response := s3.GetFailureResponse(err)
+		response := storage.GetFailureResponse(err)
 		return c.JSON(http.StatusInternalServerError, response)
 	}
 
-	response := s3.GetListFolderSuccessResponse(objects)
+	if objects.NextPageToken != "" {
+		next := position
+		next.Token = objects.NextPageToken
+		objects.NextPageToken = cursors.Encode(next)
+	} else if position.Token == "" {
+		// a folder that fits on the first page is counted already
+		total := int(objects.NoOfRecordsReturned)
+		objects.TotalCount = &total
+	}
+
+	response := storage.GetListFolderSuccessResponse(objects)
 	return c.JSON(http.StatusOK, response)
 }
 
-func listAllFilesHandler(c echo.Context, config *config.Config) error {
-	// Create a new S3 client
-	client, err := s3.NewClient(config) // Update with your desired region
+// parseFilterOptions reads the FilterFiles options from a query and reports
+// whether any filter was requested.
+func parseFilterOptions(query url.Values) (storage.FilterOptions, bool, error) {
+	options := storage.FilterOptions{
+		SizeRange:          query.Get("sizeRange"),
+		TimeRange:          query.Get("timeRange"),
+		FilenameQuery:      query.Get("filenameQuery"),
+		FilenameFilterType: query.Get("filenameFilterType"),
+		FileSizeFilterType: query.Get("fileSizeFilterType"),
+	}
 
-	folderPath := c.QueryParam("path")
+	if fileTypes := query.Get("fileTypes"); fileTypes != "" {
+		for _, fileType := range strings.Split(fileTypes, ",") {
+			options.FileTypes = append(options.FileTypes, strings.TrimPrefix(strings.TrimSpace(fileType), "."))
+		}
+	}
+
+	if options.FilenameQuery != "" && options.FilenameFilterType == "" {
+		options.FilenameFilterType = "contains"
+	}
 
+	if options.FileSizeFilterType != "" {
+		fileSize, err := strconv.ParseInt(query.Get("fileSize"), 10, 64)
+		if err != nil || fileSize < 0 {
+			return options, false, errors.New("fileSize must be a number of bytes")
+		}
+		options.FileSize = fileSize
+	}
+
+	location, err := parseLocation(query)
 	if err != nil {
-		response := s3.GetFailureResponse(err)
-		return c.JSON(http.StatusInternalServerError, response)
+		return options, false, err
 	}
+	options.Location = location
 
-	// List all the files and folders within the nested folder
-	objects, err := client.ListAllFiles(folderPath)
+	options.Expression, err = parseExpression(query, location)
+	if err != nil {
+		return options, false, err
+	}
+
+	for _, bound := range []struct {
+		param string
+		value *time.Time
+		end   bool
+	}{{"from", &options.ModifiedFrom, false}, {"to", &options.ModifiedTo, true}} {
+		if value := query.Get(bound.param); value != "" {
+			t, err := storage.ParseTimestamp(value, options.Location, bound.end)
+			if err != nil {
+				return options, false, fmt.Errorf("%s: %w", bound.param, err)
+			}
+			*bound.value = t
+		}
+	}
+
+	for _, bound := range []struct {
+		param string
+		value **int64
+	}{{"minSize", &options.MinSize}, {"maxSize", &options.MaxSize}} {
+		if value := query.Get(bound.param); value != "" {
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return options, false, fmt.Errorf("%s must be a number of bytes", bound.param)
+			}
+			*bound.value = &size
+		}
+	}
+
+	if err := options.Validate(); err != nil {
+		return options, false, err
+	}
+
+	filtered := options.SizeRange != "" || options.TimeRange != "" || options.FileTypes != nil ||
+		options.FilenameQuery != "" || options.FileSizeFilterType != "" ||
+		!options.ModifiedFrom.IsZero() || !options.ModifiedTo.IsZero() ||
+		options.MinSize != nil || options.MaxSize != nil || options.Expression != nil
 
+	return options, filtered, nil
+}
+
+// parseLocation returns the time zone named by the tz query parameter, or nil
+// when there is none.
+func parseLocation(query url.Values) (*time.Location, error) {
+	tz := query.Get("tz")
+	if tz == "" {
+		return nil, nil
+	}
+
+	location, err := time.LoadLocation(tz)
 	if err != nil {
-		response := s3.GetFailureResponse(err)
-		return c.JSON(http.StatusInternalServerError, response)
+		return nil, fmt.Errorf("unknown time zone %q", tz)
 	}
 
-	return c.JSON(http.StatusOK, objects)
+	return location, nil
+}
+
+// parseExpression parses the filter query parameter, see package filter for
+// the syntax. It returns nil when there is none.
+func parseExpression(query url.Values, location *time.Location) (storage.Matcher, error) {
+	input := query.Get("filter")
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+
+	expression, err := filter.Parse(input, location)
+	if err != nil {
+		return nil, err
+	}
+
+	return expression, nil
 }
 
-func listAllFoldersHandler(c echo.Context, config *config.Config) error {
-	// Create a new S3 client
-	client, err := s3.NewClient(config) // Update with your desired region
+// Stream every file below a folder as newline delimited JSON
+func listAllFilesHandler(c echo.Context, buckets *storage.Registry) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
 	folderPath := c.QueryParam("path")
 
+	options := storage.WalkOptions{}
+
+	if value := c.QueryParam("maxDepth"); value != "" {
+		options.MaxDepth, err = strconv.Atoi(value)
+		if err != nil || options.MaxDepth < 0 {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("maxDepth must be 0 (unlimited) or a positive number")))
+		}
+	}
+
+	for _, pattern := range c.QueryParams()["include"] {
+		glob, err := storage.CompileGlob(pattern)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+		}
+		options.Include = append(options.Include, glob)
+	}
+
+	for _, pattern := range c.QueryParams()["exclude"] {
+		glob, err := storage.CompileGlob(pattern)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+		}
+		options.Exclude = append(options.Exclude, glob)
+	}
+
+	location, err := parseLocation(c.QueryParams())
 	if err != nil {
-		response := s3.GetFailureResponse(err)
-		return c.JSON(http.StatusInternalServerError, response)
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	options.Filter, err = parseExpression(c.QueryParams(), location)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	response.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(response)
+	count := 0
+
+	err = client.Walk(c.Request().Context(), folderPath, options, func(object storage.ObjectDetails) error {
+		if err := encoder.Encode(object); err != nil {
+			return err
+		}
+
+		// flush regularly so clients can start processing right away
+		count++
+		if count%1000 == 0 {
+			response.Flush()
+		}
+
+		return nil
+	})
+
+	// The status has already been sent, so a failure is reported as the last
+	// line of the stream
+	if err != nil && c.Request().Context().Err() == nil {
+		return encoder.Encode(storage.GetFailureResponse(err))
 	}
 
+	return nil
+}
+
+func listAllFoldersHandler(c echo.Context, buckets *storage.Registry) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	folderPath := c.QueryParam("path")
+
 	// List all the files and folders within the nested folder
-	objects := client.ListAllFolders(folderPath)
+	objects, err := client.ListAllFolders(c.Request().Context(), folderPath)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	}
 
 	return c.JSON(http.StatusOK, objects)
 }
 
-// Handler for downloading a file
-func downloadFileHandler(c echo.Context, config *config.Config, cache *cache.URLCache) error {
-	key := c.QueryParam("path")
+// Return the folder hierarchy below a folder, down to the requested depth
+func folderTreeHandler(c echo.Context, buckets *storage.Registry) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	depth := 1
+	if value := c.QueryParam("depth"); value != "" {
+		depth, err = strconv.Atoi(value)
+		if err != nil || depth < 0 || depth > 20 {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("depth must be between 0 and 20")))
+		}
+	}
 
-	// Create a new S3 client
-	client, err := s3.NewClient(config) // Update with your desired region
+	tree, err := client.Tree(c.Request().Context(), c.QueryParam("path"), depth)
 	if err != nil {
-		response := s3.GetFailureResponse(err)
-		return c.JSON(http.StatusInternalServerError, response)
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         tree,
+	})
+}
+
+// Handler for downloading a file
+func downloadFileHandler(c echo.Context, buckets *storage.Registry, cache *cache.URLCache) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
 	}
 
+	key := c.QueryParam("path")
+
 	url, err := client.GenerateDownloadLink(key, cache)
 
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, s3.GetFailureResponse(err))
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
 	}
 
 	// Get the fileName, ignoring folders in prefix.
@@ -250,7 +752,7 @@ func downloadFileHandler(c echo.Context, config *config.Config, cache *cache.URL
 
 	if fileName != "" {
 		return c.JSON(http.StatusOK,
-			s3.SuccessResponse{
+			storage.SuccessResponse{
 				Status:       "Success",
 				ResponseCode: http.StatusOK,
 				Data: map[string]string{
@@ -260,53 +762,950 @@ func downloadFileHandler(c echo.Context, config *config.Config, cache *cache.URL
 			})
 	}
 
-	return c.JSON(http.StatusInternalServerError, s3.GetFailureResponse(err))
+	return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
 }
 
-func deleteFileHandler(c echo.Context, config *config.Config, cache *cache.URLCache) error {
-	// bucket := c.QueryParam("bucket")
-	path := c.QueryParam("path")
-
-	// Create a new S3 client
-	client, err := s3.NewClient(config) // Update with your desired region
+// Stream the contents of a file through the service, for clients that cannot
+// reach the storage provider directly
+func streamFileHandler(c echo.Context, buckets *storage.Registry) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
 	if err != nil {
-		response := s3.GetFailureResponse(err)
-		return c.JSON(http.StatusInternalServerError, response)
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
 	}
 
-	// Delete the file or folder from the S3 bucket
-	err = client.DeleteObject(path)
-	if err != nil {
-		response := s3.GetFailureResponse(err)
-		return c.JSON(http.StatusInternalServerError, response)
+	key := c.QueryParam("path")
+	if key == "" || strings.HasSuffix(key, "/") {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("path of a file is required")))
 	}
 
-	// Return a success response
-	response := s3.GetSuccessResponse("File deleted successfully")
-	return c.JSON(http.StatusOK, response)
+	return serveObject(c, client, key)
 }
 
-func deleteFolderHandler(c echo.Context, config *config.Config) error {
-	// bucket := c.QueryParam("bucket")
-	folderPath := c.QueryParam("path")
-
-	// Create a new S3 client
-	client, err := s3.NewClient(config) // Update with your desired region
+// Return the metadata of a single object. HEAD requests only get the
+// metadata as headers, so clients can check for existence and freshness.
+func statHandler(c echo.Context, buckets *storage.Registry) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
 	if err != nil {
-		response := s3.GetFailureResponse(err)
-		return c.JSON(http.StatusInternalServerError, response)
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	key := c.QueryParam("path")
+	if key == "" {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("path is required")))
 	}
 
-	// Delete the file or folder from the S3 bucket
-	err = client.DeleteFolder(folderPath)
+	info, err := client.Backend().Stat(c.Request().Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, storage.FailureResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusNotFound,
+			ErrorMessage: fmt.Sprintf("object %q not found", key),
+		})
+	}
 	if err != nil {
-		response := s3.GetFailureResponse(err)
-		return c.JSON(http.StatusInternalServerError, response)
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
 	}
 
-	// Return a success response
-	response := s3.GetSuccessResponse("Folder deleted successfully")
-	return c.JSON(http.StatusOK, response)
+	header := c.Response().Header()
+	header.Set(echo.HeaderLastModified, info.LastModified.UTC().Format(http.TimeFormat))
+	if info.ETag != "" {
+		header.Set("ETag", info.ETag)
+	}
+
+	if notModified(c.Request(), info) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		contentType := info.ContentType
+		if contentType == "" {
+			contentType = echo.MIMEOctetStream
+		}
+
+		header.Set(echo.HeaderContentType, contentType)
+		header.Set(echo.HeaderContentLength, strconv.FormatInt(info.Size, 10))
+		for name, value := range map[string]string{
+			"Cache-Control":            info.CacheControl,
+			"Content-Encoding":         info.ContentEncoding,
+			"Content-Disposition":      info.ContentDisposition,
+			"Content-Language":         info.ContentLanguage,
+			"Expires":                  info.Expires,
+			"X-Storage-Class":          info.StorageClass,
+			"X-Server-Side-Encryption": info.ServerSideEncryption,
+			"X-Version-Id":             info.VersionID,
+		} {
+			if value != "" {
+				header.Set(name, value)
+			}
+		}
+		for name, value := range info.Metadata {
+			header.Set("X-Meta-"+name, value)
+		}
+
+		c.Response().WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	// tags take a request of their own, so only GET fetches them
+	if tagReader, ok := client.Backend().(storage.TagReader); ok {
+		info.Tags, err = tagReader.GetTags(c.Request().Context(), key)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+		}
+	}
+
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         info,
+	})
+}
+
+// notModified evaluates If-None-Match and If-Modified-Since against an object.
+func notModified(r *http.Request, info *storage.ObjectInfo) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, etag := range strings.Split(match, ",") {
+			etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+			if etag == "*" || (info.ETag != "" && etag == info.ETag) {
+				return true
+			}
+		}
+
+		// If-Modified-Since is ignored when If-None-Match is present
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !info.LastModified.Truncate(time.Second).After(since)
+}
+
+func deleteFileHandler(c echo.Context, buckets *storage.Registry, trashBin *trash.Trash) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	path := c.QueryParam("path")
+
+	// an empty path would be the root of the bucket
+	if path == "" || path == "/" {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("path is required")))
+	}
+
+	// Move the file to the trash, unless it is disabled or skipped
+	if permanent, _ := strconv.ParseBool(c.QueryParam("permanent")); trashBin != nil && !permanent {
+		result, err := trashBin.Delete(c.Request().Context(), client, path)
+		return trashResponse(c, result, err)
+	}
+
+	// Delete the file or folder from the bucket
+	err = client.DeleteObject(c.Request().Context(), path)
+	if err != nil {
+		response := storage.GetFailureResponse(err)
+		return c.JSON(http.StatusInternalServerError, response)
+	}
+
+	// Return a success response
+	response := storage.GetSuccessResponse("File deleted successfully")
+	return c.JSON(http.StatusOK, response)
+}
+
+// Move a file to a new key, resolving an existing destination with the
+// requested conflict policy
+func moveFileHandler(c echo.Context, buckets *storage.Registry, cache *cache.URLCache) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	source := c.QueryParam("path")
+	destination := c.QueryParam("destination")
+
+	// an empty destination is the root of the bucket, but it has to be given
+	if source == "" || !c.QueryParams().Has("destination") {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("path and destination are required")))
+	}
+
+	policy, err := storage.ParseConflictPolicy(c.QueryParam("conflict"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	moved, err := client.Move(c.Request().Context(), source, destination, policy, cache)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return c.JSON(http.StatusNotFound, storage.FailureResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusNotFound,
+			ErrorMessage: err.Error(),
+		})
+	case errors.Is(err, storage.ErrExists):
+		return c.JSON(http.StatusConflict, storage.FailureResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusConflict,
+			ErrorMessage: err.Error(),
+		})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data: map[string]string{
+			"source":      source,
+			"destination": moved,
+		},
+	})
+}
+
+func moveFolderHandler(c echo.Context, buckets *storage.Registry, jobManager *jobs.Manager) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	source := c.QueryParam("path")
+
+	// an empty destination is the root of the bucket, but it has to be given
+	if source == "" || !c.QueryParams().Has("destination") {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("path and destination are required")))
+	}
+
+	policy, err := storage.ParseConflictPolicy(c.QueryParam("conflict"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	job, err := jobManager.MoveFolder(c.Request().Context(), client.Name(), source, c.QueryParam("destination"), policy)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return c.JSON(http.StatusNotFound, storage.FailureResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusNotFound,
+			ErrorMessage: err.Error(),
+		})
+	case err != nil:
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	return c.JSON(http.StatusAccepted, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusAccepted,
+		Data:         job,
+	})
+}
+
+func copyFileHandler(c echo.Context, buckets *storage.Registry, cache *cache.URLCache) error {
+	// Resolve the client of the selected bucket and of the one copied to
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	target := client
+	if targetBucket := c.QueryParam("targetBucket"); targetBucket != "" {
+		target, err = buckets.Client(targetBucket)
+		if err != nil {
+			return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+		}
+	}
+
+	source := c.QueryParam("path")
+
+	// an empty destination is the root of the bucket, but it has to be given
+	if source == "" || !c.QueryParams().Has("destination") {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("path and destination are required")))
+	}
+
+	policy, err := storage.ParseConflictPolicy(c.QueryParam("conflict"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	options, err := parseCopyOptions(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	copied, err := client.Copy(c.Request().Context(), source, target, c.QueryParam("destination"), policy, options, cache)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return c.JSON(http.StatusNotFound, storage.FailureResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusNotFound,
+			ErrorMessage: err.Error(),
+		})
+	case errors.Is(err, storage.ErrExists):
+		return c.JSON(http.StatusConflict, storage.FailureResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusConflict,
+			ErrorMessage: err.Error(),
+		})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data: map[string]string{
+			"source":      source,
+			"bucket":      target.Name(),
+			"destination": copied,
+		},
+	})
+}
+
+func copyFolderHandler(c echo.Context, buckets *storage.Registry, jobManager *jobs.Manager) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	// the target bucket is checked against the allowlist like the source
+	targetBucket := c.QueryParam("targetBucket")
+	if targetBucket != "" {
+		if _, err := buckets.Client(targetBucket); err != nil {
+			return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+		}
+	}
+
+	source := c.QueryParam("path")
+
+	// an empty destination is the root of the bucket, but it has to be given
+	if source == "" || !c.QueryParams().Has("destination") {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("path and destination are required")))
+	}
+
+	policy, err := storage.ParseConflictPolicy(c.QueryParam("conflict"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	options, err := parseCopyOptions(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	job, err := jobManager.CopyFolder(c.Request().Context(), client.Name(), source, targetBucket, c.QueryParam("destination"), policy, options)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return c.JSON(http.StatusNotFound, storage.FailureResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusNotFound,
+			ErrorMessage: err.Error(),
+		})
+	case err != nil:
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	return c.JSON(http.StatusAccepted, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusAccepted,
+		Data:         job,
+	})
+}
+
+// parseCopyOptions reads the options of a copy: metadata=replace replaces
+// the user metadata with the X-Meta-<name> headers of the request and the
+// content type with the contentType parameter, and storageClass sets the
+// storage class of the copy.
+func parseCopyOptions(c echo.Context) (storage.CopyOptions, error) {
+	options := storage.CopyOptions{
+		StorageClass: c.QueryParam("storageClass"),
+	}
+
+	switch c.QueryParam("metadata") {
+	case "", "copy":
+		if c.QueryParams().Has("contentType") {
+			return options, errors.New("contentType requires metadata=replace")
+		}
+	case "replace":
+		options.ReplaceMetadata = true
+		options.ContentType = c.QueryParam("contentType")
+		options.Metadata = map[string]string{}
+
+		for name, values := range c.Request().Header {
+			if key, found := strings.CutPrefix(name, "X-Meta-"); found && len(values) > 0 {
+				options.Metadata[strings.ToLower(key)] = values[0]
+			}
+		}
+	default:
+		return options, fmt.Errorf("invalid metadata %q, expected copy or replace", c.QueryParam("metadata"))
+	}
+
+	return options, nil
+}
+
+func jobHandler(c echo.Context, jobManager *jobs.Manager) error {
+	job, err := jobManager.Get(c.Param("id"))
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		return c.JSON(http.StatusNotFound, storage.FailureResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusNotFound,
+			ErrorMessage: err.Error(),
+		})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         job,
+	})
+}
+
+func resumeJobHandler(c echo.Context, jobManager *jobs.Manager) error {
+	job, err := jobManager.Resume(c.Param("id"))
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		return c.JSON(http.StatusNotFound, storage.FailureResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusNotFound,
+			ErrorMessage: err.Error(),
+		})
+	case errors.Is(err, jobs.ErrJobRunning), errors.Is(err, jobs.ErrJobCompleted):
+		return c.JSON(http.StatusConflict, storage.FailureResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusConflict,
+			ErrorMessage: err.Error(),
+		})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	}
+
+	return c.JSON(http.StatusAccepted, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusAccepted,
+		Data:         job,
+	})
+}
+
+func deleteFolderHandler(c echo.Context, buckets *storage.Registry, trashBin *trash.Trash) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	folderPath := c.QueryParam("path")
+
+	// an empty path would delete every object in the bucket
+	if folderPath == "" || folderPath == "/" {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(storage.ErrRootFolder))
+	}
+
+	// Move the folder to the trash, unless it is disabled or skipped
+	if permanent, _ := strconv.ParseBool(c.QueryParam("permanent")); trashBin != nil && !permanent {
+		result, err := trashBin.DeleteFolder(c.Request().Context(), client, folderPath)
+		return trashResponse(c, result, err)
+	}
+
+	// Delete the file or folder from the bucket
+	result, err := client.DeleteFolder(c.Request().Context(), folderPath)
+	if err == nil && result.Failed > 0 {
+		err = fmt.Errorf("failed to delete %d objects, first %s: %s", result.Failed, result.Errors[0].Key, result.Errors[0].Error)
+	}
+	if err != nil {
+		response := storage.GetFailureResponse(err)
+		return c.JSON(http.StatusInternalServerError, response)
+	}
+
+	// Return a success response
+	response := storage.GetSuccessResponse("Folder deleted successfully")
+	return c.JSON(http.StatusOK, response)
+}
+
+// maxBulkDeleteEntries bounds the keys and prefixes of one bulk delete.
+const maxBulkDeleteEntries = 10000
+
+// bulkDeleteRequest is the body of a bulk delete.
+type bulkDeleteRequest struct {
+	Keys     []string `json:"keys"`
+	Prefixes []string `json:"prefixes"`
+}
+
+func bulkDeleteHandler(c echo.Context, buckets *storage.Registry) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	var request bulkDeleteRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(fmt.Errorf("invalid request body: %w", err)))
+	}
+
+	entries := len(request.Keys) + len(request.Prefixes)
+	if entries == 0 || entries > maxBulkDeleteEntries {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(fmt.Errorf("between 1 and %d keys and prefixes are required", maxBulkDeleteEntries)))
+	}
+
+	// an empty prefix would be the whole bucket
+	for _, key := range append(request.Keys, request.Prefixes...) {
+		if key == "" {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("keys and prefixes must not be empty")))
+		}
+	}
+
+	result, err := client.BulkDelete(c.Request().Context(), request.Keys, request.Prefixes)
+	return deleteResponse(c, result, err)
+}
+
+// deleteResponse answers a delete of many objects. The keys that could not
+// be deleted are listed in the result, answered with 207.
+func deleteResponse(c echo.Context, result *storage.DeleteResult, err error) error {
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	}
+
+	if result.Failed > 0 {
+		return c.JSON(http.StatusMultiStatus, storage.SuccessResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusMultiStatus,
+			Data:         result,
+		})
+	}
+
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         result,
+	})
+}
+
+// trashResponse answers a move into or out of the trash. Objects that could
+// not be moved are listed in the result, answered with 207.
+func trashResponse(c echo.Context, result *trash.Result, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, trash.ErrEntryNotFound):
+		return c.JSON(http.StatusNotFound, storage.FailureResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusNotFound,
+			ErrorMessage: err.Error(),
+		})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	case result.Failed > 0:
+		return c.JSON(http.StatusMultiStatus, storage.SuccessResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusMultiStatus,
+			Data:         result,
+		})
+	}
+
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         result,
+	})
+}
+
+// trashDisabled answers the trash endpoints when the trash is disabled.
+func trashDisabled(c echo.Context) error {
+	return c.JSON(http.StatusNotImplemented, storage.GetFailureResponse(errors.New("the trash is disabled")))
+}
+
+func listTrashHandler(c echo.Context, buckets *storage.Registry, trashBin *trash.Trash) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	if trashBin == nil {
+		return trashDisabled(c)
+	}
+
+	entries, err := trashBin.List(c.Request().Context(), client)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         entries,
+	})
+}
+
+func restoreTrashHandler(c echo.Context, buckets *storage.Registry, trashBin *trash.Trash) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	if trashBin == nil {
+		return trashDisabled(c)
+	}
+
+	policy, err := storage.ParseConflictPolicy(c.QueryParam("conflict"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	result, err := trashBin.Restore(c.Request().Context(), client, c.Param("id"), policy)
+	return trashResponse(c, result, err)
+}
+
+func removeTrashHandler(c echo.Context, buckets *storage.Registry, trashBin *trash.Trash) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	if trashBin == nil {
+		return trashDisabled(c)
+	}
+
+	result, err := trashBin.Remove(c.Request().Context(), client, c.Param("id"))
+	if errors.Is(err, trash.ErrEntryNotFound) {
+		return c.JSON(http.StatusNotFound, storage.FailureResponse{
+			Status:       "Failure",
+			ResponseCode: http.StatusNotFound,
+			ErrorMessage: err.Error(),
+		})
+	}
+
+	return deleteResponse(c, result, err)
+}
+
+func emptyTrashHandler(c echo.Context, buckets *storage.Registry, trashBin *trash.Trash) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	if trashBin == nil {
+		return trashDisabled(c)
+	}
+
+	result, err := trashBin.Empty(c.Request().Context(), client)
+	return deleteResponse(c, result, err)
+}
+
+// Handler for the links generated by storage.LinkVerifier backends
+func serveSignedFileHandler(c echo.Context, buckets *storage.Registry) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	verifier, ok := client.Backend().(storage.LinkVerifier)
+	if !ok {
+		return c.JSON(http.StatusNotFound, storage.GetFailureResponse(storage.ErrNotFound))
+	}
+
+	key, err := url.PathUnescape(c.Param("*"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(err))
+	}
+
+	expires, err := strconv.ParseInt(c.QueryParam("expires"), 10, 64)
+	if err != nil || !verifier.VerifyLink(key, expires, c.QueryParam("signature")) {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(errors.New("invalid or expired download link")))
+	}
+
+	return serveObject(c, client, key)
+}
+
+// serveObject streams an object to the client. Range requests and conditional
+// requests against the ETag and modification time of the object are answered
+// by http.ServeContent; only the requested bytes are read from the backend.
+func serveObject(c echo.Context, client *storage.Client, key string) error {
+	info, err := client.Backend().Stat(c.Request().Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, storage.GetFailureResponse(err))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	}
+
+	// S3 stores binary/octet-stream for objects uploaded without a type
+	contentType := info.ContentType
+	if contentType == "" || contentType == "binary/octet-stream" || contentType == echo.MIMEOctetStream {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("inline", map[string]string{"filename": path.Base(key)}))
+	if info.ETag != "" {
+		header.Set("ETag", info.ETag)
+	}
+
+	body := storage.NewObjectReader(c.Request().Context(), client.Backend(), info)
+	body.ExpectRanges(c.Request().Header.Get("Range"))
+	defer body.Close()
+
+	http.ServeContent(c.Response(), c.Request(), path.Base(key), info.LastModified, body)
+	return nil
+}
+
+// Summarize the storage used below a folder: totals, the largest files and
+// breakdowns by extension and child folder
+func usageHandler(c echo.Context, buckets *storage.Registry, usageCache *cache.TTLCache[*storage.FolderUsage]) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	folderPath := c.QueryParam("path")
+
+	// If the folder path does not end with a slash, add it, so both forms
+	// share one cached summary
+	if folderPath != "" && !strings.HasSuffix(folderPath, "/") {
+		folderPath += "/"
+	}
+
+	largest := 10
+	if value := c.QueryParam("largest"); value != "" {
+		largest, err = strconv.Atoi(value)
+		if err != nil || largest < 0 || largest > 1000 {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("largest must be between 0 and 1000")))
+		}
+	}
+
+	refresh, _ := strconv.ParseBool(c.QueryParam("refresh"))
+
+	key := client.Name() + "\x00" + folderPath + "\x00" + strconv.Itoa(largest)
+	usage, err := usageCache.Get(key, refresh, func() (*storage.FolderUsage, error) {
+		// the summary is shared with other requests, so it must not be
+		// cancelled when this one goes away
+		return client.Usage(context.Background(), folderPath, largest)
+	})
+
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         usage,
+	})
+}
+
+// Search the metadata index of a bucket by name, extension, size, date,
+// content type and tags
+func searchHandler(c echo.Context, buckets *storage.Registry, indexer *index.Indexer) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	idx, err := bucketIndex(indexer, client)
+	if err != nil {
+		return c.JSON(http.StatusNotImplemented, storage.GetFailureResponse(err))
+	}
+
+	query := index.Query{
+		Name:        c.QueryParam("q"),
+		Prefix:      c.QueryParam("path"),
+		ContentType: c.QueryParam("contentType"),
+		MaxSize:     -1,
+		Limit:       100,
+	}
+
+	if value := c.QueryParam("ext"); value != "" {
+		for _, ext := range strings.Split(value, ",") {
+			query.Extensions = append(query.Extensions, strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")))
+		}
+	}
+
+	for _, tag := range c.QueryParams()["tag"] {
+		key, value, found := strings.Cut(tag, ":")
+		if !found {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("tag must be given as key:value")))
+		}
+		if query.Tags == nil {
+			query.Tags = make(map[string]string)
+		}
+		query.Tags[key] = value
+	}
+
+	numbers := []struct {
+		name  string
+		value *int64
+	}{
+		{"minSize", &query.MinSize},
+		{"maxSize", &query.MaxSize},
+	}
+	for _, number := range numbers {
+		if value := c.QueryParam(number.name); value != "" {
+			*number.value, err = strconv.ParseInt(value, 10, 64)
+			if err != nil || *number.value < 0 {
+				return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(fmt.Errorf("%s must be a number of bytes", number.name)))
+			}
+		}
+	}
+
+	if value := c.QueryParam("limit"); value != "" {
+		query.Limit, err = strconv.Atoi(value)
+		if err != nil || query.Limit <= 0 || query.Limit > 1000 {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("limit must be between 1 and 1000")))
+		}
+	}
+
+	if value := c.QueryParam("offset"); value != "" {
+		query.Offset, err = strconv.Atoi(value)
+		if err != nil || query.Offset < 0 || query.Offset > 10000 {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("offset must be between 0 and 10000")))
+		}
+	}
+
+	times := []struct {
+		name  string
+		value *time.Time
+	}{
+		{"modifiedAfter", &query.ModifiedAfter},
+		{"modifiedBefore", &query.ModifiedBefore},
+	}
+	for _, t := range times {
+		if value := c.QueryParam(t.name); value != "" {
+			*t.value, err = time.Parse(time.RFC3339, value)
+			if err != nil {
+				*t.value, err = time.Parse(time.DateOnly, value)
+			}
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(fmt.Errorf("%s must be a RFC 3339 time or a date", t.name)))
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         idx.Search(query),
+	})
+}
+
+// Search the full text index of a bucket, scoped to a folder like /list
+func searchTextHandler(c echo.Context, buckets *storage.Registry, indexer *index.Indexer) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	if _, err := bucketIndex(indexer, client); err != nil {
+		return c.JSON(http.StatusNotImplemented, storage.GetFailureResponse(err))
+	}
+
+	query := index.TextQuery{
+		Text:   c.QueryParam("q"),
+		Any:    c.QueryParam("match") == "any",
+		Prefix: c.QueryParam("path"),
+		Limit:  20,
+	}
+
+	if strings.TrimSpace(query.Text) == "" {
+		return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("q is required")))
+	}
+
+	// If the folder path does not end with a slash, add it
+	if query.Prefix != "" && !strings.HasSuffix(query.Prefix, "/") {
+		query.Prefix += "/"
+	}
+
+	if value := c.QueryParam("recursive"); value != "" {
+		query.Recursive, err = strconv.ParseBool(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("recursive must be true or false")))
+		}
+	}
+
+	if value := c.QueryParam("limit"); value != "" {
+		query.Limit, err = strconv.Atoi(value)
+		if err != nil || query.Limit <= 0 || query.Limit > 100 {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("limit must be between 1 and 100")))
+		}
+	}
+
+	if value := c.QueryParam("offset"); value != "" {
+		query.Offset, err = strconv.Atoi(value)
+		if err != nil || query.Offset < 0 || query.Offset > 10000 {
+			return c.JSON(http.StatusBadRequest, storage.GetFailureResponse(errors.New("offset must be between 0 and 10000")))
+		}
+	}
+
+	result, err := indexer.SearchText(c.Request().Context(), client.Name(), query)
+	if err != nil {
+		return c.JSON(http.StatusNotImplemented, storage.GetFailureResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         result,
+	})
+}
+
+// Rebuild the metadata index of a bucket in the background
+func rebuildIndexHandler(c echo.Context, buckets *storage.Registry, indexer *index.Indexer) error {
+	// Resolve the client of the selected bucket
+	client, err := resolveClient(c, buckets)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, storage.GetFailureResponse(err))
+	}
+
+	if _, err := bucketIndex(indexer, client); err != nil {
+		return c.JSON(http.StatusNotImplemented, storage.GetFailureResponse(err))
+	}
+
+	// the crawl outlives the request
+	err = indexer.Rebuild(client.Name())
+	if errors.Is(err, index.ErrRebuilding) {
+		return c.JSON(http.StatusConflict, storage.GetFailureResponse(err))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, storage.GetFailureResponse(err))
+	}
+
+	return c.JSON(http.StatusAccepted, storage.GetSuccessResponse("Index rebuild started"))
+}
+
+// bucketIndex returns the metadata index of the client's bucket.
+func bucketIndex(indexer *index.Indexer, client *storage.Client) (*index.Index, error) {
+	if indexer == nil {
+		return nil, errors.New("the metadata index is disabled")
+	}
+
+	return indexer.Index(client.Name())
+}
+
+// List the names of all buckets on the allowlist
+func listBucketsHandler(c echo.Context, buckets *storage.Registry) error {
+	return c.JSON(http.StatusOK, storage.SuccessResponse{
+		Status:       "Success",
+		ResponseCode: http.StatusOK,
+		Data:         buckets.Names(),
+	})
+}
+
+// resolveClient returns the client for the bucket selected with the "bucket"
+// query parameter, falling back to the default bucket.
+func resolveClient(c echo.Context, buckets *storage.Registry) (*storage.Client, error) {
+	return buckets.Client(c.QueryParam("bucket"))
 }
 
 // ping is a simple handler to test the server
@@ -0,0 +1,177 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"file-management-service/config"
+	"file-management-service/pkg/cache"
+	"file-management-service/pkg/memory"
+	"file-management-service/pkg/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newTestServer registers the routes against an in-memory bucket, without
+// uploads, index, jobs or trash.
+func newTestServer(t *testing.T) *echo.Echo {
+	t.Helper()
+
+	appConfig := &config.Config{
+		Name:               "test",
+		PublicURL:          "http://localhost",
+		URLSigningKey:      "secret",
+		PaginationPageSize: 100,
+	}
+
+	backend, err := memory.NewClient(appConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buckets := storage.NewRegistry("test")
+	buckets.Register(storage.NewClient("test", backend))
+
+	cursors, err := storage.NewCursorSigner(appConfig.URLSigningKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	RegisterRoutes(e, appConfig, buckets, nil, nil, cache.NewURLCache(), cache.NewTTLCache[*storage.FolderUsage](0), cursors, nil, nil)
+
+	return e
+}
+
+func serve(e *echo.Echo, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// upload uploads a file through /upload.
+func upload(t *testing.T, e *echo.Echo, folder string, name string, content string) {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	form.WriteField("path", folder)
+	part, _ := form.CreateFormFile("file", name)
+	part.Write([]byte(content))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+
+	if rec := serve(e, req); rec.Code != http.StatusOK {
+		t.Fatalf("upload of %s: %d %s", name, rec.Code, rec.Body)
+	}
+}
+
+// list returns the names listed by /list for query.
+func list(t *testing.T, e *echo.Echo, query string) ([]string, storage.ListFilesResponse) {
+	t.Helper()
+
+	rec := serve(e, httptest.NewRequest(http.MethodGet, "/list?"+query, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list %s: %d %s", query, rec.Code, rec.Body)
+	}
+
+	var body struct {
+		Data storage.ListFilesResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	response := body.Data
+
+	names := []string{}
+	if response.Files != nil {
+		for _, file := range *response.Files {
+			names = append(names, file.Name)
+		}
+	}
+
+	return names, response
+}
+
+func TestFileLifecycle(t *testing.T) {
+	e := newTestServer(t)
+
+	if rec := serve(e, httptest.NewRequest(http.MethodPost, "/create-folder?path=docs", nil)); rec.Code != http.StatusOK {
+		t.Fatalf("create-folder: %d %s", rec.Code, rec.Body)
+	}
+
+	upload(t, e, "docs", "a.txt", "hello world")
+
+	if names, _ := list(t, e, "path=docs"); len(names) != 1 || names[0] != "docs/a.txt" {
+		t.Fatalf("list docs = %q, want docs/a.txt", names)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/stream?path=docs/a.txt", nil)
+	req.Header.Set("Range", "bytes=6-10")
+	rec := serve(e, req)
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "world" {
+		t.Fatalf("stream range: %d %q, want 206 \"world\"", rec.Code, rec.Body)
+	}
+	if contentType := rec.Header().Get(echo.HeaderContentType); contentType != "text/plain; charset=utf-8" {
+		t.Errorf("stream content type = %q", contentType)
+	}
+
+	if rec := serve(e, httptest.NewRequest(http.MethodDelete, "/delete?path=docs/a.txt", nil)); rec.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := serve(e, httptest.NewRequest(http.MethodGet, "/stream?path=docs/a.txt", nil)); rec.Code != http.StatusNotFound {
+		t.Fatalf("stream of a deleted file: %d, want 404", rec.Code)
+	}
+}
+
+func TestDeleteRootIsRejected(t *testing.T) {
+	e := newTestServer(t)
+	upload(t, e, "", "a.txt", "a")
+
+	for _, target := range []string{
+		"/delete?path=",
+		"/delete?path=/",
+		"/delete-folder?path=",
+		"/delete-folder?path=/",
+	} {
+		if rec := serve(e, httptest.NewRequest(http.MethodDelete, target, nil)); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d, want 400", target, rec.Code)
+		}
+	}
+
+	if names, _ := list(t, e, ""); len(names) != 1 {
+		t.Errorf("bucket holds %q after deleting the root, want a.txt", names)
+	}
+}
+
+// TestCursorPastTheEnd continues a sorted listing after the folder shrank
+// below the offset of the cursor.
+func TestCursorPastTheEnd(t *testing.T) {
+	e := newTestServer(t)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		upload(t, e, "docs", name, name)
+	}
+
+	_, first := list(t, e, "path=docs&sortBy=name&pageSize=2")
+	if first.IsLastPage || first.NextPageToken == "" {
+		t.Fatalf("first page is the last one")
+	}
+
+	for _, name := range []string{"b.txt", "c.txt"} {
+		if rec := serve(e, httptest.NewRequest(http.MethodDelete, "/delete?path=docs/"+name, nil)); rec.Code != http.StatusOK {
+			t.Fatalf("delete: %d %s", rec.Code, rec.Body)
+		}
+	}
+
+	names, next := list(t, e, "cursor="+first.NextPageToken)
+	if len(names) != 0 || !next.IsLastPage {
+		t.Errorf("page past the end holds %q, last page %v; want an empty last page", names, next.IsLastPage)
+	}
+}